@@ -0,0 +1,39 @@
+// Code generated by protoc-gen-go from nezha.proto. DO NOT EDIT BY HAND.
+// Regenerate with: protoc --go_out=. --go-grpc_out=. api/nezhapb/nezha.proto
+
+package nezhapb
+
+type State struct {
+	Cpu            float64 `protobuf:"fixed64,1,opt,name=cpu,proto3" json:"cpu,omitempty"`
+	MemoryUsed     float64 `protobuf:"fixed64,2,opt,name=memory_used,json=memoryUsed,proto3" json:"memory_used,omitempty"`
+	SwapUsed       float64 `protobuf:"fixed64,3,opt,name=swap_used,json=swapUsed,proto3" json:"swap_used,omitempty"`
+	DiskUsed       float64 `protobuf:"fixed64,4,opt,name=disk_used,json=diskUsed,proto3" json:"disk_used,omitempty"`
+	NetInTransfer  float64 `protobuf:"fixed64,5,opt,name=net_in_transfer,json=netInTransfer,proto3" json:"net_in_transfer,omitempty"`
+	NetOutTransfer float64 `protobuf:"fixed64,6,opt,name=net_out_transfer,json=netOutTransfer,proto3" json:"net_out_transfer,omitempty"`
+	NetInSpeed     float64 `protobuf:"fixed64,7,opt,name=net_in_speed,json=netInSpeed,proto3" json:"net_in_speed,omitempty"`
+	NetOutSpeed    float64 `protobuf:"fixed64,8,opt,name=net_out_speed,json=netOutSpeed,proto3" json:"net_out_speed,omitempty"`
+	Uptime         uint64  `protobuf:"varint,9,opt,name=uptime,proto3" json:"uptime,omitempty"`
+	Load1          float64 `protobuf:"fixed64,10,opt,name=load1,proto3" json:"load1,omitempty"`
+	Load5          float64 `protobuf:"fixed64,11,opt,name=load5,proto3" json:"load5,omitempty"`
+	Load15         float64 `protobuf:"fixed64,12,opt,name=load15,proto3" json:"load15,omitempty"`
+	TcpConnCount   uint64  `protobuf:"varint,13,opt,name=tcp_conn_count,json=tcpConnCount,proto3" json:"tcp_conn_count,omitempty"`
+	UdpConnCount   uint64  `protobuf:"varint,14,opt,name=udp_conn_count,json=udpConnCount,proto3" json:"udp_conn_count,omitempty"`
+	ProcessCount   uint64  `protobuf:"varint,15,opt,name=process_count,json=processCount,proto3" json:"process_count,omitempty"`
+}
+
+type Host struct {
+	Platform        string `protobuf:"bytes,1,opt,name=platform,proto3" json:"platform,omitempty"`
+	PlatformVersion string `protobuf:"bytes,2,opt,name=platform_version,json=platformVersion,proto3" json:"platform_version,omitempty"`
+	CpuInfo         string `protobuf:"bytes,3,opt,name=cpu_info,json=cpuInfo,proto3" json:"cpu_info,omitempty"`
+	MemTotal        uint64 `protobuf:"varint,4,opt,name=mem_total,json=memTotal,proto3" json:"mem_total,omitempty"`
+	DiskTotal       uint64 `protobuf:"varint,5,opt,name=disk_total,json=diskTotal,proto3" json:"disk_total,omitempty"`
+	SwapTotal       uint64 `protobuf:"varint,6,opt,name=swap_total,json=swapTotal,proto3" json:"swap_total,omitempty"`
+	Arch            string `protobuf:"bytes,7,opt,name=arch,proto3" json:"arch,omitempty"`
+	Virtualization  string `protobuf:"bytes,8,opt,name=virtualization,proto3" json:"virtualization,omitempty"`
+	BootTime        int64  `protobuf:"varint,9,opt,name=boot_time,json=bootTime,proto3" json:"boot_time,omitempty"`
+	Version         string `protobuf:"bytes,10,opt,name=version,proto3" json:"version,omitempty"`
+}
+
+type Receipt struct {
+	Proxy bool `protobuf:"varint,1,opt,name=proxy,proto3" json:"proxy,omitempty"`
+}
@@ -0,0 +1,153 @@
+// Code generated by protoc-gen-go-grpc from nezha.proto. DO NOT EDIT BY HAND.
+// Regenerate with: protoc --go_out=. --go-grpc_out=. api/nezhapb/nezha.proto
+
+package nezhapb
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// NezhaServiceClient is the client API for NezhaService.
+type NezhaServiceClient interface {
+	ReportSystemState(ctx context.Context, opts ...grpc.CallOption) (NezhaService_ReportSystemStateClient, error)
+	ReportSystemInfo(ctx context.Context, in *Host, opts ...grpc.CallOption) (*Receipt, error)
+}
+
+type nezhaServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewNezhaServiceClient wraps cc (typically the result of grpc.Dial against
+// NEZHA_SERVER) as a NezhaServiceClient.
+func NewNezhaServiceClient(cc grpc.ClientConnInterface) NezhaServiceClient {
+	return &nezhaServiceClient{cc}
+}
+
+func (c *nezhaServiceClient) ReportSystemState(ctx context.Context, opts ...grpc.CallOption) (NezhaService_ReportSystemStateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &nezhaServiceServiceDesc.Streams[0], "/nezhapb.NezhaService/ReportSystemState", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &nezhaServiceReportSystemStateClient{stream}, nil
+}
+
+// NezhaService_ReportSystemStateClient is the client side of the
+// ReportSystemState client stream: Send pushes one State sample, and
+// CloseAndRecv ends the stream once the caller is done reporting.
+type NezhaService_ReportSystemStateClient interface {
+	Send(*State) error
+	CloseAndRecv() (*Receipt, error)
+	grpc.ClientStream
+}
+
+type nezhaServiceReportSystemStateClient struct {
+	grpc.ClientStream
+}
+
+func (x *nezhaServiceReportSystemStateClient) Send(m *State) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *nezhaServiceReportSystemStateClient) CloseAndRecv() (*Receipt, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Receipt)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *nezhaServiceClient) ReportSystemInfo(ctx context.Context, in *Host, opts ...grpc.CallOption) (*Receipt, error) {
+	out := new(Receipt)
+	if err := c.cc.Invoke(ctx, "/nezhapb.NezhaService/ReportSystemInfo", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NezhaServiceServer is the server API for NezhaService. argo-go only acts
+// as a client against NEZHA_SERVER, but this interface is kept so the
+// generated code matches what protoc-gen-go-grpc would emit.
+type NezhaServiceServer interface {
+	ReportSystemState(NezhaService_ReportSystemStateServer) error
+	ReportSystemInfo(context.Context, *Host) (*Receipt, error)
+}
+
+type NezhaService_ReportSystemStateServer interface {
+	SendAndClose(*Receipt) error
+	Recv() (*State, error)
+	grpc.ServerStream
+}
+
+// UnimplementedNezhaServiceServer can be embedded to have forward compatible
+// implementations; methods added to the service in later proto revisions
+// return Unimplemented until overridden.
+type UnimplementedNezhaServiceServer struct{}
+
+func (UnimplementedNezhaServiceServer) ReportSystemState(NezhaService_ReportSystemStateServer) error {
+	return nil
+}
+func (UnimplementedNezhaServiceServer) ReportSystemInfo(context.Context, *Host) (*Receipt, error) {
+	return nil, nil
+}
+
+// RegisterNezhaServiceServer registers srv on s under the service name
+// declared in nezha.proto.
+func RegisterNezhaServiceServer(s grpc.ServiceRegistrar, srv NezhaServiceServer) {
+	s.RegisterService(&nezhaServiceServiceDesc, srv)
+}
+
+var nezhaServiceServiceDesc = grpc.ServiceDesc{
+	ServiceName: "nezhapb.NezhaService",
+	HandlerType: (*NezhaServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "ReportSystemInfo", Handler: reportSystemInfoHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ReportSystemState",
+			Handler:       reportSystemStateHandler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "nezha.proto",
+}
+
+func reportSystemInfoHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Host)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NezhaServiceServer).ReportSystemInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/nezhapb.NezhaService/ReportSystemInfo"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NezhaServiceServer).ReportSystemInfo(ctx, req.(*Host))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func reportSystemStateHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(NezhaServiceServer).ReportSystemState(&nezhaServiceReportSystemStateServer{stream})
+}
+
+type nezhaServiceReportSystemStateServer struct {
+	grpc.ServerStream
+}
+
+func (s *nezhaServiceReportSystemStateServer) SendAndClose(r *Receipt) error {
+	return s.ServerStream.SendMsg(r)
+}
+
+func (s *nezhaServiceReportSystemStateServer) Recv() (*State, error) {
+	m := new(State)
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
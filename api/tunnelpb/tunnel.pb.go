@@ -0,0 +1,47 @@
+// Code generated by protoc-gen-go from tunnel.proto. DO NOT EDIT BY HAND.
+// Regenerate with: protoc --go_out=. --go-grpc_out=. api/tunnelpb/tunnel.proto
+
+package tunnelpb
+
+type StatusRequest struct{}
+
+type StatusEvent struct {
+	Process       string `protobuf:"bytes,1,opt,name=process,proto3" json:"process,omitempty"`
+	Running       bool   `protobuf:"varint,2,opt,name=running,proto3" json:"running,omitempty"`
+	Retries       int32  `protobuf:"varint,3,opt,name=retries,proto3" json:"retries,omitempty"`
+	UptimeHuman   string `protobuf:"bytes,4,opt,name=uptime_human,json=uptimeHuman,proto3" json:"uptime_human,omitempty"`
+	UptimeSeconds int64  `protobuf:"varint,5,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	TunnelType    string `protobuf:"bytes,6,opt,name=tunnel_type,json=tunnelType,proto3" json:"tunnel_type,omitempty"`
+	TunnelDomain  string `protobuf:"bytes,7,opt,name=tunnel_domain,json=tunnelDomain,proto3" json:"tunnel_domain,omitempty"`
+}
+
+type AnalyzeRequest struct {
+	ArgoAuth string `protobuf:"bytes,1,opt,name=argo_auth,json=argoAuth,proto3" json:"argo_auth,omitempty"`
+}
+
+type AnalyzeResponse struct {
+	TunnelType string `protobuf:"bytes,1,opt,name=tunnel_type,json=tunnelType,proto3" json:"tunnel_type,omitempty"`
+}
+
+type StartRequest struct {
+	Process string `protobuf:"bytes,1,opt,name=process,proto3" json:"process,omitempty"`
+}
+
+type StopRequest struct {
+	Process string `protobuf:"bytes,1,opt,name=process,proto3" json:"process,omitempty"`
+}
+
+type RestartRequest struct {
+	Process string `protobuf:"bytes,1,opt,name=process,proto3" json:"process,omitempty"`
+}
+
+type RotateCredentialsRequest struct{}
+
+type SwitchTunnelTypeRequest struct {
+	TunnelType string `protobuf:"bytes,1,opt,name=tunnel_type,json=tunnelType,proto3" json:"tunnel_type,omitempty"`
+}
+
+type ActionResponse struct {
+	Success bool   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+}
@@ -0,0 +1,189 @@
+// Code generated by protoc-gen-go-grpc from tunnel.proto. DO NOT EDIT BY HAND.
+// Regenerate with: protoc --go_out=. --go-grpc_out=. api/tunnelpb/tunnel.proto
+
+package tunnelpb
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// TunnelControlServer is the server API for the TunnelControl service.
+type TunnelControlServer interface {
+	StreamStatus(*StatusRequest, TunnelControl_StreamStatusServer) error
+	Analyze(context.Context, *AnalyzeRequest) (*AnalyzeResponse, error)
+	Start(context.Context, *StartRequest) (*ActionResponse, error)
+	Stop(context.Context, *StopRequest) (*ActionResponse, error)
+	Restart(context.Context, *RestartRequest) (*ActionResponse, error)
+	RotateCredentials(context.Context, *RotateCredentialsRequest) (*ActionResponse, error)
+	SwitchTunnelType(context.Context, *SwitchTunnelTypeRequest) (*ActionResponse, error)
+}
+
+// TunnelControl_StreamStatusServer is implemented by grpc and passed to the
+// StreamStatus handler so it can push events for as long as the client stays
+// connected.
+type TunnelControl_StreamStatusServer interface {
+	Send(*StatusEvent) error
+	grpc.ServerStream
+}
+
+// UnimplementedTunnelControlServer can be embedded to have forward compatible
+// implementations; methods added to the service in later proto revisions
+// return Unimplemented until overridden.
+type UnimplementedTunnelControlServer struct{}
+
+func (UnimplementedTunnelControlServer) StreamStatus(*StatusRequest, TunnelControl_StreamStatusServer) error {
+	return nil
+}
+func (UnimplementedTunnelControlServer) Analyze(context.Context, *AnalyzeRequest) (*AnalyzeResponse, error) {
+	return nil, nil
+}
+func (UnimplementedTunnelControlServer) Start(context.Context, *StartRequest) (*ActionResponse, error) {
+	return nil, nil
+}
+func (UnimplementedTunnelControlServer) Stop(context.Context, *StopRequest) (*ActionResponse, error) {
+	return nil, nil
+}
+func (UnimplementedTunnelControlServer) Restart(context.Context, *RestartRequest) (*ActionResponse, error) {
+	return nil, nil
+}
+func (UnimplementedTunnelControlServer) RotateCredentials(context.Context, *RotateCredentialsRequest) (*ActionResponse, error) {
+	return nil, nil
+}
+func (UnimplementedTunnelControlServer) SwitchTunnelType(context.Context, *SwitchTunnelTypeRequest) (*ActionResponse, error) {
+	return nil, nil
+}
+
+// RegisterTunnelControlServer registers srv on s under the service name
+// declared in tunnel.proto.
+func RegisterTunnelControlServer(s grpc.ServiceRegistrar, srv TunnelControlServer) {
+	s.RegisterService(&tunnelControlServiceDesc, srv)
+}
+
+var tunnelControlServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tunnelpb.TunnelControl",
+	HandlerType: (*TunnelControlServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Analyze", Handler: analyzeHandler},
+		{MethodName: "Start", Handler: startHandler},
+		{MethodName: "Stop", Handler: stopHandler},
+		{MethodName: "Restart", Handler: restartHandler},
+		{MethodName: "RotateCredentials", Handler: rotateCredentialsHandler},
+		{MethodName: "SwitchTunnelType", Handler: switchTunnelTypeHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamStatus",
+			Handler:       streamStatusHandler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tunnel.proto",
+}
+
+func analyzeHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnalyzeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TunnelControlServer).Analyze(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/tunnelpb.TunnelControl/Analyze"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TunnelControlServer).Analyze(ctx, req.(*AnalyzeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func startHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TunnelControlServer).Start(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/tunnelpb.TunnelControl/Start"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TunnelControlServer).Start(ctx, req.(*StartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func stopHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TunnelControlServer).Stop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/tunnelpb.TunnelControl/Stop"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TunnelControlServer).Stop(ctx, req.(*StopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func restartHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TunnelControlServer).Restart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/tunnelpb.TunnelControl/Restart"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TunnelControlServer).Restart(ctx, req.(*RestartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func rotateCredentialsHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateCredentialsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TunnelControlServer).RotateCredentials(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/tunnelpb.TunnelControl/RotateCredentials"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TunnelControlServer).RotateCredentials(ctx, req.(*RotateCredentialsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func switchTunnelTypeHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SwitchTunnelTypeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TunnelControlServer).SwitchTunnelType(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/tunnelpb.TunnelControl/SwitchTunnelType"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TunnelControlServer).SwitchTunnelType(ctx, req.(*SwitchTunnelTypeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func streamStatusHandler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TunnelControlServer).StreamStatus(m, &tunnelControlStreamStatusServer{stream})
+}
+
+type tunnelControlStreamStatusServer struct {
+	grpc.ServerStream
+}
+
+func (s *tunnelControlStreamStatusServer) Send(e *StatusEvent) error {
+	return s.ServerStream.SendMsg(e)
+}
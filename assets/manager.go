@@ -0,0 +1,214 @@
+package assets
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Installed描述一个Manager已经验证并缓存过的资产，经由StatusHandler上报。
+type Installed struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	SHA256  string `json:"sha256"`
+	Path    string `json:"path"`
+}
+
+// Manager根据Manifest解析具名资产，把验证过的二进制缓存在
+// <filePath>/cache/<sha256>下，使重跑和重试永远不会重新信任一个无法验证的
+// 下载。
+type Manager struct {
+	cacheDir string
+	manifest *Manifest
+	pubKey   string
+	client   *http.Client
+
+	mu        sync.Mutex
+	installed map[string]Installed
+}
+
+// NewManager构建一个缓存在filePath/cache下的Manager。pubKey是可选的base64
+// ed25519公钥，用于验证PlatformAsset.Signature；为空时忽略签名，只检查digest。
+func NewManager(filePath string, manifest *Manifest, pubKey string) *Manager {
+	return &Manager{
+		cacheDir:  filepath.Join(filePath, "cache"),
+		manifest:  manifest,
+		pubKey:    pubKey,
+		client:    &http.Client{},
+		installed: make(map[string]Installed),
+	}
+}
+
+// Ensure返回name在arch（"amd"/"arm"，与getSystemArchitecture一致）下已缓存、
+// 经digest验证的路径；尚未缓存时会下载（或续传一个未完成的下载）。
+func (m *Manager) Ensure(name, arch string) (string, error) {
+	spec, ok := m.manifest.Assets[name]
+	if !ok {
+		return "", fmt.Errorf("assets: manifest has no entry for %q", name)
+	}
+	platform, ok := spec.Platforms[arch]
+	if !ok {
+		return "", fmt.Errorf("assets: %q has no build for platform %q", name, arch)
+	}
+	if platform.SHA256 == "" {
+		return "", fmt.Errorf("assets: %q is missing a sha256 digest, refusing to fetch it unverified", name)
+	}
+
+	cachePath := filepath.Join(m.cacheDir, strings.ToLower(platform.SHA256))
+	if !verifyDigest(cachePath, platform.SHA256) {
+		if err := os.MkdirAll(m.cacheDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create asset cache dir: %v", err)
+		}
+		if err := m.download(platform.URL, platform.SHA256, cachePath); err != nil {
+			return "", err
+		}
+		if err := os.Chmod(cachePath, 0755); err != nil {
+			return "", fmt.Errorf("failed to set permissions for %s: %v", name, err)
+		}
+	}
+
+	if err := m.verifySignature(platform); err != nil {
+		return "", err
+	}
+
+	m.recordInstalled(name, spec.Version, platform, cachePath)
+	return cachePath, nil
+}
+
+// download把url的内容经由一个".partial"同名文件流式写入destPath；磁盘上已有
+// 未完成的下载时用HTTP Range请求续传，并在流式写入过程中就校验SHA256 digest
+// 而不是等下载完再校验。
+func (m *Manager) download(url, expectedSHA256, destPath string) error {
+	partialPath := destPath + ".partial"
+
+	var resumeFrom int64
+	if fi, err := os.Stat(partialPath); err == nil {
+		resumeFrom = fi.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request for %s: %v", url, err)
+	}
+	if resumeFrom > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", resumeFrom))
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	hasher := sha256.New()
+	flags := os.O_CREATE | os.O_WRONLY
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+		if existing, err := os.Open(partialPath); err == nil {
+			_, _ = io.Copy(hasher, existing)
+			existing.Close()
+		}
+	case http.StatusOK:
+		flags |= os.O_TRUNC
+	default:
+		return fmt.Errorf("failed to download %s: status %d", url, resp.StatusCode)
+	}
+
+	out, err := os.OpenFile(partialPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", partialPath, err)
+	}
+
+	if _, err := io.Copy(out, io.TeeReader(resp.Body, hasher)); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write %s: %v", partialPath, err)
+	}
+	out.Close()
+
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	if !strings.EqualFold(sum, expectedSHA256) {
+		os.Remove(partialPath)
+		return fmt.Errorf("assets: digest mismatch for %s: expected %s, got %s", url, expectedSHA256, sum)
+	}
+
+	if err := os.Rename(partialPath, destPath); err != nil {
+		return fmt.Errorf("failed to finalize %s: %v", destPath, err)
+	}
+	return nil
+}
+
+// verifySignature在两者都设置时，用m.pubKey校验platform.Signature（对原始
+// SHA256 digest字节的base64 ed25519签名）。
+func (m *Manager) verifySignature(platform PlatformAsset) error {
+	if m.pubKey == "" || platform.Signature == "" {
+		return nil
+	}
+
+	pubKey, err := base64.StdEncoding.DecodeString(m.pubKey)
+	if err != nil {
+		return fmt.Errorf("assets: invalid ASSET_PUBKEY: %v", err)
+	}
+	if len(pubKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("assets: ASSET_PUBKEY has unexpected length %d", len(pubKey))
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(platform.Signature)
+	if err != nil {
+		return fmt.Errorf("assets: invalid signature: %v", err)
+	}
+
+	digest, err := hex.DecodeString(platform.SHA256)
+	if err != nil {
+		return fmt.Errorf("assets: invalid sha256: %v", err)
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(pubKey), digest, sig) {
+		return fmt.Errorf("assets: signature verification failed")
+	}
+	return nil
+}
+
+// verifyDigest报告path是否存在且哈希值等于expectedSHA256。
+func verifyDigest(path, expectedSHA256 string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	return strings.EqualFold(hex.EncodeToString(h.Sum(nil)), expectedSHA256)
+}
+
+func (m *Manager) recordInstalled(name, version string, platform PlatformAsset, path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.installed[name] = Installed{Name: name, Version: version, SHA256: platform.SHA256, Path: path}
+}
+
+// Installed按name排序，返回目前为止Ensure验证过的所有资产。
+func (m *Manager) Installed() []Installed {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Installed, 0, len(m.installed))
+	for _, inst := range m.installed {
+		out = append(out, inst)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,104 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnsureDownloadsVerifiesAndCaches(t *testing.T) {
+	const payload = "pretend this is a binary"
+	sum := sha256.Sum256([]byte(payload))
+	digest := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	manifest := &Manifest{
+		Assets: map[string]AssetSpec{
+			"web": {
+				Version: "1.0.0",
+				Platforms: map[string]PlatformAsset{
+					"amd": {URL: server.URL, SHA256: digest},
+				},
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	mgr := NewManager(dir, manifest, "")
+
+	path, err := mgr.Ensure("web", "amd")
+	if err != nil {
+		t.Fatalf("Ensure失败: %v", err)
+	}
+	if filepath.Dir(path) != filepath.Join(dir, "cache") {
+		t.Fatalf("期望缓存路径在%s/cache下，实际: %s", dir, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取缓存资产失败: %v", err)
+	}
+	if string(data) != payload {
+		t.Fatalf("缓存内容不符合预期: %q", data)
+	}
+
+	installed := mgr.Installed()
+	if len(installed) != 1 || installed[0].Name != "web" || installed[0].SHA256 != digest {
+		t.Fatalf("Installed()结果不符合预期: %+v", installed)
+	}
+
+	// 第二次Ensure应该直接从缓存返回，不会再次请求server。
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("资产已缓存后不应该再次请求server")
+	})
+	if _, err := mgr.Ensure("web", "amd"); err != nil {
+		t.Fatalf("缓存命中的Ensure失败: %v", err)
+	}
+}
+
+func TestEnsureRejectsDigestMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not what we expected"))
+	}))
+	defer server.Close()
+
+	manifest := &Manifest{
+		Assets: map[string]AssetSpec{
+			"bot": {
+				Platforms: map[string]PlatformAsset{
+					"amd": {URL: server.URL, SHA256: "0000000000000000000000000000000000000000000000000000000000000000"},
+				},
+			},
+		},
+	}
+
+	mgr := NewManager(t.TempDir(), manifest, "")
+	if _, err := mgr.Ensure("bot", "amd"); err == nil {
+		t.Fatal("期望得到digest不匹配错误，实际为nil")
+	}
+}
+
+func TestEnsureRequiresDigest(t *testing.T) {
+	manifest := &Manifest{
+		Assets: map[string]AssetSpec{
+			"agent": {
+				Platforms: map[string]PlatformAsset{
+					"amd": {URL: "https://example.invalid/agent"},
+				},
+			},
+		},
+	}
+
+	mgr := NewManager(t.TempDir(), manifest, "")
+	if _, err := mgr.Ensure("agent", "amd"); err == nil {
+		t.Fatal("期望platform asset缺少sha256时返回错误")
+	}
+}
@@ -0,0 +1,68 @@
+// Package assets 验证并缓存daemon要shell out的辅助二进制（web/bot/agent/v1），
+// 用一份逐平台描述预期digest（以及可选签名）的manifest取代原来的裸下载。
+package assets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// PlatformAsset是一个资产在某个platform/arch下的构建。arch的取值与daemon的
+// getSystemArchitecture()一致（"amd"/"arm"），而不是完整的GOOS/GOARCH，因为
+// 原有下载逻辑一直只需要这一种划分。
+type PlatformAsset struct {
+	URL string `json:"url"`
+	// SHA256是必填项：没有digest可供校验时，Manager.Ensure拒绝安装，
+	// 而不是未经验证就下载。
+	SHA256 string `json:"sha256"`
+	// Signature是可选的、对原始SHA256 digest字节的base64 ed25519签名，
+	// 由Manager.pubKey校验（类似minisign/cosign：manifest携带签名，
+	// 一个pin住的带外公钥负责验证）。
+	Signature string `json:"signature,omitempty"`
+}
+
+// AssetSpec描述一个具名资产在各平台下的情况。
+type AssetSpec struct {
+	Version   string                   `json:"version"`
+	Platforms map[string]PlatformAsset `json:"platforms"`
+}
+
+// Manifest是从Config.AssetManifestURL获取的顶层文档。
+type Manifest struct {
+	Assets map[string]AssetSpec `json:"assets"`
+}
+
+// LoadManifest从一个http(s) URL或本地文件路径读取Manifest，使运维既可以pin
+// 自己的镜像，也可以用一份打进镜像里的文件。
+func LoadManifest(source string) (*Manifest, error) {
+	var data []byte
+	var err error
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		resp, ferr := http.Get(source)
+		if ferr != nil {
+			return nil, fmt.Errorf("failed to fetch asset manifest: %v", ferr)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch asset manifest: status %d", resp.StatusCode)
+		}
+		data, err = io.ReadAll(resp.Body)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset manifest: %v", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse asset manifest: %v", err)
+	}
+	return &manifest, nil
+}
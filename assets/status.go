@@ -0,0 +1,15 @@
+package assets
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// StatusHandler把已安装资产列表以JSON形式提供，用于挂载在例如
+// /assets/status上。
+func (m *Manager) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m.Installed())
+	})
+}
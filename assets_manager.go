@@ -0,0 +1,24 @@
+package main
+
+import (
+	"argo-go/assets"
+)
+
+// assetManager在Config.AssetManifestURL未设置时为nil；此时downloadFiles会
+// 退回旧的、未经验证的downloadFile路径。
+var assetManager *assets.Manager
+
+// initAssetManager在设置了cfg.AssetManifestURL时加载它，并构建供fetchAsset
+// 在安装前验证和缓存二进制用的Manager。
+func initAssetManager(cfg *Config) (*assets.Manager, error) {
+	if cfg.AssetManifestURL == "" {
+		return nil, nil
+	}
+
+	manifest, err := assets.LoadManifest(cfg.AssetManifestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return assets.NewManager(cfg.FilePath, manifest, cfg.AssetPubKey), nil
+}
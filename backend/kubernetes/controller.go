@@ -0,0 +1,184 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// AnalyzeFunc把一个ArgoAuth字符串分类成隧道类型名；调用方把自己的
+// analyzeTunnelType接到这里，使operator校验CR时用的规则和daemon启动时
+// 完全一致。
+type AnalyzeFunc func(argoAuth string) string
+
+// TunnelReconciler为一个Tunnel CR启动（或重启）隧道，并报告是否启动成功。
+type TunnelReconciler func(ctx context.Context, t *Tunnel) error
+
+// Controller监听Tunnel CRD，为每个CR各自协调一个隧道。
+type Controller struct {
+	Clientset    kubernetes.Interface
+	Analyze      AnalyzeFunc
+	GenerateName func(length int) string
+	FormatUptime func(d time.Duration) string
+	Reconcile    TunnelReconciler
+	LeaseName    string
+	LeaseNS      string
+	Identity     string
+
+	startTimes map[string]time.Time
+}
+
+// NewController接上operator需要复用的main三块既有逻辑：隧道类型判定、
+// 名称生成、以及uptime格式化。
+func NewController(clientset kubernetes.Interface, analyze AnalyzeFunc, generateName func(int) string, formatUptime func(time.Duration) string, reconcile TunnelReconciler) *Controller {
+	return &Controller{
+		Clientset:    clientset,
+		Analyze:      analyze,
+		GenerateName: generateName,
+		FormatUptime: formatUptime,
+		Reconcile:    reconcile,
+		startTimes:   make(map[string]time.Time),
+	}
+}
+
+// resolveSecret读取引用的Secret key，使TunnelTypeFixed类型的CR可以完全不把
+// ArgoAuth写进CR本体。
+func (c *Controller) resolveSecret(ctx context.Context, namespace string, ref *SecretKeyRef) (string, error) {
+	secret, err := c.Clientset.CoreV1().Secrets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s/%s: %v", namespace, ref.Name, err)
+	}
+
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s/%s has no key %q", namespace, ref.Name, ref.Key)
+	}
+	return string(value), nil
+}
+
+// admit在admission路径（或没有接validating webhook的集群里，在reconcile时）
+// 校验CR的argoAuth，在为它启动Server之前就拒绝格式错误的JSON/token。
+func (c *Controller) admit(argoAuth string) error {
+	if argoAuth == "" {
+		return nil
+	}
+	if _, err := base64.StdEncoding.DecodeString(argoAuth); err != nil {
+		var probe map[string]interface{}
+		if json.Unmarshal([]byte(argoAuth), &probe) != nil {
+			return fmt.Errorf("argoAuth is neither valid JSON nor a base64 token")
+		}
+	}
+	return nil
+}
+
+// reconcileOne解析secret、校验spec、在未指定时生成隧道名、调用调用方的
+// reconciler，并填好Status。
+func (c *Controller) reconcileOne(ctx context.Context, t *Tunnel) {
+	argoAuth := t.Spec.ArgoAuth
+	if t.Spec.SecretRef != nil {
+		resolved, err := c.resolveSecret(ctx, t.Namespace, t.Spec.SecretRef)
+		if err != nil {
+			t.Status = TunnelStatus{Ready: false, Reason: err.Error()}
+			return
+		}
+		argoAuth = resolved
+	}
+
+	if err := c.admit(argoAuth); err != nil {
+		t.Status = TunnelStatus{Ready: false, Reason: err.Error()}
+		return
+	}
+
+	name := t.Spec.Name
+	if name == "" {
+		name = c.GenerateName(6)
+	}
+
+	if _, ok := c.startTimes[t.Name]; !ok {
+		c.startTimes[t.Name] = time.Now()
+	}
+
+	if err := c.Reconcile(ctx, t); err != nil {
+		t.Status = TunnelStatus{Ready: false, Reason: err.Error(), Name: name}
+		return
+	}
+
+	t.Status = TunnelStatus{
+		Ready:       true,
+		Name:        name,
+		TunnelType:  c.Analyze(argoAuth),
+		Uptime:      c.FormatUptime(time.Since(c.startTimes[t.Name])),
+		ObservedGen: t.Generation,
+	}
+}
+
+// Run启动leader election，选举成功后持续消费informer事件、协调Tunnel CR，
+// 直到ctx被取消。
+func (c *Controller) Run(ctx context.Context, informer cache.SharedIndexInformer) error {
+	lock, err := resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		c.LeaseNS,
+		c.LeaseName,
+		c.Clientset.CoreV1(),
+		c.Clientset.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: c.Identity},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to build leader election lock: %v", err)
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.handle(ctx, obj) },
+		UpdateFunc: func(_, obj interface{}) { c.handle(ctx, obj) },
+	})
+	go informer.Run(ctx.Done())
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Println("Became leader, reconciling Tunnel CRs")
+				<-ctx.Done()
+			},
+			OnStoppedLeading: func() {
+				log.Println("Lost leadership, stepping down")
+			},
+		},
+	})
+
+	return nil
+}
+
+func (c *Controller) handle(ctx context.Context, obj interface{}) {
+	t, ok := obj.(*Tunnel)
+	if !ok {
+		return
+	}
+	c.reconcileOne(ctx, t)
+}
+
+// ServeProbes暴露/healthz和/readyz，使operator可以作为带标准存活/就绪探针
+// 的Deployment运行。
+func (c *Controller) ServeProbes(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return http.ListenAndServe(addr, mux)
+}
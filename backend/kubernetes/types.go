@@ -0,0 +1,55 @@
+// Package kubernetes 实现一种operator模式：为每个隧道协调（reconcile）一个
+// Tunnel自定义资源，而不是像Config/DaemonManager那样只跑单个隧道，使得一批
+// argo隧道可以作为Deployment以声明式的方式运行。
+package kubernetes
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TunnelSpec是面向用户的、某个隧道的期望状态。
+type TunnelSpec struct {
+	// ArgoAuth要么是固定隧道的JSON凭证，要么是一个token；留空时reconciler
+	// 会转为provisioning一个quick隧道。
+	ArgoAuth string `json:"argoAuth,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Service  string `json:"service"`
+	// Name设置时会覆盖自动生成的隧道名。
+	Name string `json:"name,omitempty"`
+	// SecretRef指向保存TunnelTypeFixed隧道ArgoAuth的Secret，使凭证不必
+	// 直接写在CR里。
+	SecretRef *SecretKeyRef `json:"secretRef,omitempty"`
+}
+
+// SecretKeyRef指向同一namespace下某个Secret里的一个key。
+type SecretKeyRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// TunnelStatus由reconciler写回到CR上。
+type TunnelStatus struct {
+	TunnelType  string `json:"tunnelType,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Uptime      string `json:"uptime,omitempty"`
+	Ready       bool   `json:"ready"`
+	Reason      string `json:"reason,omitempty"`
+	ObservedGen int64  `json:"observedGeneration,omitempty"`
+}
+
+// Tunnel是CRD对象本身：对应`kubectl get tunnels`。
+type Tunnel struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TunnelSpec   `json:"spec"`
+	Status TunnelStatus `json:"status,omitempty"`
+}
+
+// TunnelList是client-go informer要求的列表形式。
+type TunnelList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Tunnel `json:"items"`
+}
@@ -0,0 +1,95 @@
+package cfoptimizer
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//go:embed ips-v4.txt
+var bundledIPv4 string
+
+//go:embed ips-v6.txt
+var bundledIPv6 string
+
+// loadIPPool fetches Cloudflare's published IPv4/IPv6 ranges, falling back to
+// the bundled copies (embedded at build time) for either family that can't
+// be reached, and expands every CIDR into one representative sample address.
+func loadIPPool(cfg Config) []string {
+	v4 := fetchLines(cfg.IPv4URL, bundledIPv4)
+	v6 := fetchLines(cfg.IPv6URL, bundledIPv6)
+
+	var ips []string
+	for _, cidr := range append(v4, v6...) {
+		ips = append(ips, expandCIDRSample(cidr)...)
+	}
+	return ips
+}
+
+// fetchLines downloads url and scans it for CIDR lines; on any error it
+// falls back to scanning fallback (one of the embedded bundled lists).
+func fetchLines(url, fallback string) []string {
+	resp, err := http.Get(url)
+	if err != nil {
+		return scanLines(fallback)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return scanLines(fallback)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return scanLines(fallback)
+	}
+	if lines := scanLines(string(body)); len(lines) > 0 {
+		return lines
+	}
+	return scanLines(fallback)
+}
+
+// scanLines parses one CIDR per non-blank, non-comment line.
+func scanLines(text string) []string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// expandCIDRSample returns the network address of cidr as a single
+// representative probe target; Cloudflare's anycast ranges route every
+// address in a block to the same edge, so probing one per CIDR is
+// sufficient and keeps refresh() from dialing entire /15s.
+func expandCIDRSample(cidr string) []string {
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil
+	}
+	_ = ip
+	return []string{ipNet.IP.String()}
+}
+
+// sampleIPs bounds ips to at most n entries via a random shuffle, so each
+// refresh probes a fresh cross-section instead of always the first n CIDRs.
+func sampleIPs(ips []string, n int) []string {
+	if len(ips) <= n {
+		return ips
+	}
+	shuffled := make([]string, len(ips))
+	copy(shuffled, ips)
+	rand.New(rand.NewSource(time.Now().UnixNano())).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled[:n]
+}
@@ -0,0 +1,219 @@
+// Package cfoptimizer periodically probes a pool of Cloudflare anycast IPs
+// on a small set of commonly-proxied ports, ranks them by TCP handshake and
+// TLS ClientHello latency, and keeps a sorted best-N list. generateSubscription
+// consults it (via Best) to emit healthy CFIP candidates instead of a single
+// hardcoded IP; handleDaemonStatus surfaces the full ranking for operators.
+package cfoptimizer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultPorts are the Cloudflare-proxied ports probed when Config.Ports is
+// empty: the plaintext-HTTP-to-HTTPS upgrade port plus the three alternate
+// HTTPS ports Cloudflare exposes for networks that block 443.
+var DefaultPorts = []int{443, 2053, 2087, 2096, 8443}
+
+// Candidate is one probed (ip, port) pair and its measured latency.
+type Candidate struct {
+	IP         string        `json:"ip"`
+	Port       int           `json:"port"`
+	TCPLatency time.Duration `json:"tcpLatencyMs"`
+	TLSLatency time.Duration `json:"tlsLatencyMs"`
+	Healthy    bool          `json:"healthy"`
+	CheckedAt  time.Time     `json:"checkedAt"`
+}
+
+// Config controls where the IP pool comes from and how probing runs. All
+// fields are optional; see withDefaults for the values an empty Config gets.
+type Config struct {
+	// IPv4URL/IPv6URL are fetched on every refresh; on failure (offline,
+	// egress blocked, etc) the bundled ips-v4.txt/ips-v6.txt take over.
+	IPv4URL string
+	IPv6URL string
+
+	// Ports is the set of candidate ports probed per IP.
+	Ports []int
+
+	// TopN bounds how many ranked candidates Best returns by default.
+	TopN int
+
+	// RefreshInterval is how often the probe loop re-ranks.
+	RefreshInterval time.Duration
+
+	// ProbeTimeout bounds each individual TCP+TLS dial.
+	ProbeTimeout time.Duration
+
+	// SampleSize caps how many IPs are drawn from the pool and probed per
+	// refresh; Cloudflare's ranges are large anycast blocks so probing one
+	// representative address per CIDR is enough to judge the edge's latency.
+	SampleSize int
+}
+
+func (c Config) withDefaults() Config {
+	if len(c.Ports) == 0 {
+		c.Ports = DefaultPorts
+	}
+	if c.TopN <= 0 {
+		c.TopN = 3
+	}
+	if c.RefreshInterval <= 0 {
+		c.RefreshInterval = 30 * time.Minute
+	}
+	if c.ProbeTimeout <= 0 {
+		c.ProbeTimeout = 2 * time.Second
+	}
+	if c.SampleSize <= 0 {
+		c.SampleSize = 20
+	}
+	if c.IPv4URL == "" {
+		c.IPv4URL = "https://www.cloudflare.com/ips-v4"
+	}
+	if c.IPv6URL == "" {
+		c.IPv6URL = "https://www.cloudflare.com/ips-v6"
+	}
+	return c
+}
+
+// Optimizer owns the periodic probe loop and the last ranking it produced.
+// Safe for concurrent use: Ranking/Best take a read lock, refresh a write
+// lock, so callers on the HTTP/subscription path never block on a probe.
+type Optimizer struct {
+	cfg Config
+
+	mu     sync.RWMutex
+	ranked []Candidate
+}
+
+// New builds an Optimizer; call Start to begin probing.
+func New(cfg Config) *Optimizer {
+	return &Optimizer{cfg: cfg.withDefaults()}
+}
+
+// Start probes immediately, then again every cfg.RefreshInterval, until ctx
+// is cancelled. Intended to run in its own goroutine for the daemon's
+// lifetime, the same pattern nezha.Agent.Start and p2p.Agent.Serve use.
+func (o *Optimizer) Start(ctx context.Context) {
+	o.refresh()
+
+	ticker := time.NewTicker(o.cfg.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.refresh()
+		}
+	}
+}
+
+// Ranking returns a copy of the full current ranking, best latency first.
+func (o *Optimizer) Ranking() []Candidate {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	out := make([]Candidate, len(o.ranked))
+	copy(out, o.ranked)
+	return out
+}
+
+// Best returns up to n of the lowest-latency healthy candidates, preferring
+// one per distinct port so the result spans cfg.Ports rather than clustering
+// on whichever port happened to rank best overall; n<=0 uses cfg.TopN.
+func (o *Optimizer) Best(n int) []Candidate {
+	if n <= 0 {
+		n = o.cfg.TopN
+	}
+	ranking := o.Ranking()
+
+	var best []Candidate
+	seenPorts := make(map[int]bool, n)
+	for _, c := range ranking {
+		if len(best) >= n {
+			return best
+		}
+		if c.Healthy && !seenPorts[c.Port] {
+			seenPorts[c.Port] = true
+			best = append(best, c)
+		}
+	}
+	for _, c := range ranking {
+		if len(best) >= n {
+			break
+		}
+		if c.Healthy && seenPorts[c.Port] {
+			best = append(best, c)
+		}
+	}
+	return best
+}
+
+func (o *Optimizer) refresh() {
+	ips := loadIPPool(o.cfg)
+	if len(ips) == 0 {
+		return
+	}
+	ips = sampleIPs(ips, o.cfg.SampleSize)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	candidates := make([]Candidate, 0, len(ips)*len(o.cfg.Ports))
+	for _, ip := range ips {
+		for _, port := range o.cfg.Ports {
+			wg.Add(1)
+			go func(ip string, port int) {
+				defer wg.Done()
+				c := probe(ip, port, o.cfg.ProbeTimeout)
+				mu.Lock()
+				candidates = append(candidates, c)
+				mu.Unlock()
+			}(ip, port)
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Healthy != candidates[j].Healthy {
+			return candidates[i].Healthy
+		}
+		return candidates[i].TLSLatency < candidates[j].TLSLatency
+	})
+
+	o.mu.Lock()
+	o.ranked = candidates
+	o.mu.Unlock()
+}
+
+// probe measures TCP handshake and TLS ClientHello round-trip against
+// ip:port, leaving Healthy false if either dial fails or times out.
+// InsecureSkipVerify is deliberate: Cloudflare edge certs are issued for the
+// tunnel's own hostname, not the anycast IP being measured here, so the
+// handshake is timed for latency only, never used to carry traffic.
+func probe(ip string, port int, timeout time.Duration) Candidate {
+	c := Candidate{IP: ip, Port: port, CheckedAt: time.Now()}
+	addr := net.JoinHostPort(ip, fmt.Sprintf("%d", port))
+
+	tcpStart := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return c
+	}
+	defer conn.Close()
+	c.TCPLatency = time.Since(tcpStart)
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	tlsStart := time.Now()
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: ip, InsecureSkipVerify: true})
+	if err := tlsConn.Handshake(); err != nil {
+		return c
+	}
+	c.TLSLatency = time.Since(tlsStart)
+	c.Healthy = true
+	return c
+}
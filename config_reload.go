@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigStore holds the daemon's live Config behind an atomic pointer so
+// handlers, the WebSocket/gRPC control planes, and DaemonManager can all
+// read a consistent snapshot without locking, while reloadConfig() swaps in
+// a freshly validated Config on SIGHUP or POST /config/reload.
+type ConfigStore struct {
+	ptr atomic.Pointer[Config]
+}
+
+// NewConfigStore wraps cfg in a ConfigStore.
+func NewConfigStore(cfg *Config) *ConfigStore {
+	s := &ConfigStore{}
+	s.ptr.Store(cfg)
+	return s
+}
+
+// Load returns the current config snapshot.
+func (s *ConfigStore) Load() *Config {
+	return s.ptr.Load()
+}
+
+// Store atomically swaps in a new config snapshot.
+func (s *ConfigStore) Store(cfg *Config) {
+	s.ptr.Store(cfg)
+}
+
+// ConfigReloadStatus is what GET /config/status reports: the outcome of the
+// most recent reload attempt, kept separate from the live config so a
+// rejected reload's error is visible without exposing ArgoAuth/NezhaKey.
+type ConfigReloadStatus struct {
+	mu            sync.RWMutex
+	LastAttempt   time.Time `json:"lastAttempt"`
+	LastSuccess   time.Time `json:"lastSuccess"`
+	LastError     string    `json:"lastError,omitempty"`
+	AppliedAction []string  `json:"lastAppliedActions,omitempty"`
+}
+
+func (s *ConfigReloadStatus) snapshot() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return map[string]interface{}{
+		"lastAttempt":        s.LastAttempt,
+		"lastSuccess":        s.LastSuccess,
+		"lastError":          s.LastError,
+		"lastAppliedActions": s.AppliedAction,
+	}
+}
+
+func (s *ConfigReloadStatus) recordAttempt() {
+	s.mu.Lock()
+	s.LastAttempt = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *ConfigReloadStatus) recordSuccess(actions []string) {
+	s.mu.Lock()
+	s.LastSuccess = time.Now()
+	s.LastError = ""
+	s.AppliedAction = actions
+	s.mu.Unlock()
+}
+
+func (s *ConfigReloadStatus) recordError(err error) {
+	s.mu.Lock()
+	s.LastError = err.Error()
+	s.mu.Unlock()
+}
+
+// configReloadStatus records the outcome of the most recent reloadConfig
+// call; handleConfigStatus reports it verbatim.
+var configReloadStatus = &ConfigReloadStatus{}
+
+// uuidPattern matches a canonical 8-4-4-4-12 hex UUID, the format UUID/
+// QuickTunnelCreds fields and Xray's client id all expect.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validateConfig rejects a config reloadConfig should never swap in: out of
+// range ports, a malformed UUID, or an ArgoAuth that looks like fixed-tunnel
+// JSON but doesn't parse / is missing TunnelID.
+func validateConfig(cfg *Config) error {
+	for _, p := range []struct {
+		name string
+		port int
+	}{
+		{"SERVER_PORT", cfg.Port},
+		{"EXTERNAL_PORT", cfg.ExternalPort},
+		{"ARGO_PORT", cfg.ArgoPort},
+		{"CFPORT", cfg.CFPort},
+	} {
+		if p.port < 1 || p.port > 65535 {
+			return fmt.Errorf("%s out of range: %d", p.name, p.port)
+		}
+	}
+
+	if cfg.UUID != "" && !uuidPattern.MatchString(cfg.UUID) {
+		return fmt.Errorf("UUID is not a valid UUID: %q", cfg.UUID)
+	}
+
+	if strings.Contains(cfg.ArgoAuth, "TunnelSecret") {
+		var tunnelConfig map[string]interface{}
+		if err := json.Unmarshal([]byte(cfg.ArgoAuth), &tunnelConfig); err != nil {
+			return fmt.Errorf("ARGO_AUTH looks like fixed-tunnel JSON but does not parse: %v", err)
+		}
+		if _, ok := tunnelConfig["TunnelID"].(string); !ok {
+			return fmt.Errorf("ARGO_AUTH fixed-tunnel JSON is missing TunnelID")
+		}
+	}
+
+	return nil
+}
+
+// loadConfigFromFileAndEnv rebuilds a Config from NewConfig()'s env-var
+// defaults, then layers cfg.ConfigFile (JSON or YAML, picked by extension;
+// YAML is tried when the extension is unrecognized) on top so only the
+// fields actually present in the file override the environment.
+func loadConfigFromFileAndEnv() (*Config, error) {
+	cfg := NewConfig()
+	if cfg.ConfigFile == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(cfg.ConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CONFIG_FILE %s: %v", cfg.ConfigFile, err)
+	}
+
+	if strings.HasSuffix(cfg.ConfigFile, ".yaml") || strings.HasSuffix(cfg.ConfigFile, ".yml") {
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse CONFIG_FILE as YAML: %v", err)
+		}
+	} else if err := json.Unmarshal(data, cfg); err != nil {
+		if yamlErr := yaml.Unmarshal(data, cfg); yamlErr != nil {
+			return nil, fmt.Errorf("failed to parse CONFIG_FILE as JSON or YAML: %v", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// diffAndApply compares old against newCfg and runs only the subsystem
+// actions whose inputs actually changed, returning their names for
+// ConfigReloadStatus: a CFIP/CFPORT/NAME change only regenerates sub.txt, a
+// UUID change regenerates the Xray config and bounces xray, and an
+// ArgoDomain/ArgoAuth change bounces the tunnel.
+func diffAndApply(old, newCfg *Config) []string {
+	var actions []string
+
+	if old.CFIP != newCfg.CFIP || old.CFPort != newCfg.CFPort || old.Name != newCfg.Name {
+		daemonManager.status.mu.RLock()
+		domain := daemonManager.status.Tunnel.Domain
+		daemonManager.status.mu.RUnlock()
+		if domain != "" {
+			go generateSubscription(domain)
+		}
+		actions = append(actions, "regenerated sub.txt")
+	}
+
+	if old.UUID != newCfg.UUID {
+		if err := generateConfig(); err != nil {
+			eventLogger.Error("failed to regenerate xray config after reload", "event", "config_reload", "error", err)
+		} else {
+			go daemonManager.scheduleRestart("xray", "", nil)
+			actions = append(actions, "regenerated xray config and restarted xray")
+		}
+	}
+
+	if old.TunnelProtocol != newCfg.TunnelProtocol {
+		// 操作员显式改了协议，放弃handleQUICFallback之前记下的强制回退
+		daemonManager.mu.Lock()
+		daemonManager.forcedTunnelProtocol = ""
+		daemonManager.quicFallbackTriggered = false
+		daemonManager.mu.Unlock()
+		go daemonManager.scheduleRestart("tunnel", "", nil)
+		actions = append(actions, "applied new tunnel protocol")
+	}
+
+	if old.ArgoDomain != newCfg.ArgoDomain || old.ArgoAuth != newCfg.ArgoAuth {
+		tunnelType := analyzeTunnelType()
+		if err := prepareTunnelConfig(tunnelType); err != nil {
+			eventLogger.Error("failed to prepare tunnel config after reload", "event", "config_reload", "error", err)
+		} else {
+			daemonManager.setTunnelInfo(tunnelType, newCfg.ArgoDomain)
+			go daemonManager.scheduleRestart("tunnel", "", nil)
+			actions = append(actions, "bounced tunnel")
+		}
+	}
+
+	return actions
+}
+
+// reloadConfig re-reads CONFIG_FILE/env, validates the result, and only on
+// success atomically swaps it into configStore before diffing against the
+// previous snapshot to restart the minimal set of affected processes. On
+// any error the previous config is left in place and the error is recorded
+// on configReloadStatus for GET /config/status.
+func reloadConfig() error {
+	configReloadStatus.recordAttempt()
+
+	newCfg, err := loadConfigFromFileAndEnv()
+	if err != nil {
+		configReloadStatus.recordError(err)
+		return err
+	}
+
+	if err := validateConfig(newCfg); err != nil {
+		configReloadStatus.recordError(err)
+		return err
+	}
+
+	old := configStore.Load()
+	// 保留运行时才会填充、不应被重载覆盖的字段
+	newCfg.QuickTunnelCreds = old.QuickTunnelCreds
+
+	configStore.Store(newCfg)
+	actions := diffAndApply(old, newCfg)
+
+	configReloadStatus.recordSuccess(actions)
+	eventLogger.Info("config reloaded successfully", "actions", actions, "event", "config_reload")
+	return nil
+}
+
+// handleConfigReload 处理 POST /config/reload，校验ConfigReloadToken（留空则不做鉴权）
+// 后触发与SIGHUP相同的reloadConfig流程。
+func handleConfigReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := configStore.Load()
+	if cfg.ConfigReloadToken != "" && r.Header.Get("X-Reload-Token") != cfg.ConfigReloadToken {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := reloadConfig(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "config reloaded",
+	})
+}
+
+// handleConfigStatus 处理 GET /config/status，返回最近一次reload的结果
+func handleConfigStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    configReloadStatus.snapshot(),
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"argo-go/api/tunnelpb"
+	"google.golang.org/grpc"
+)
+
+// tunnelControlServer implements tunnelpb.TunnelControlServer on top of the
+// existing Server/DaemonManager so the lifecycle operations they already
+// expose can be driven remotely.
+type tunnelControlServer struct {
+	tunnelpb.UnimplementedTunnelControlServer
+	server        *Server
+	daemonManager *DaemonManager
+}
+
+// startGRPCServer starts the gRPC control-plane listener when Config.GRPCListen
+// is set, and wires it into the daemon's graceful-shutdown path via the
+// returned stop function.
+func startGRPCServer(cfg *Config, dm *DaemonManager) (stop func(), err error) {
+	if cfg.GRPCListen == "" {
+		return func() {}, nil
+	}
+
+	lis, err := net.Listen("tcp", cfg.GRPCListen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", cfg.GRPCListen, err)
+	}
+
+	grpcServer := grpc.NewServer()
+	tunnelpb.RegisterTunnelControlServer(grpcServer, &tunnelControlServer{
+		server:        NewServer(cfg),
+		daemonManager: dm,
+	})
+
+	go func() {
+		eventLogger.Info("gRPC control plane listening", "addr", cfg.GRPCListen, "event", "startup")
+		if err := grpcServer.Serve(lis); err != nil {
+			eventLogger.Error("gRPC server stopped", "event", "grpc_stop", "error", err)
+		}
+	}()
+
+	return grpcServer.GracefulStop, nil
+}
+
+func (s *tunnelControlServer) StreamStatus(req *tunnelpb.StatusRequest, stream tunnelpb.TunnelControl_StreamStatusServer) error {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+			s.daemonManager.status.mu.RLock()
+			uptime := time.Duration(s.daemonManager.status.Uptime) * time.Second
+			tunnelType := s.daemonManager.status.Tunnel.Type
+			tunnelDomain := s.daemonManager.status.Tunnel.Domain
+			s.daemonManager.status.mu.RUnlock()
+
+			for name, status := range s.daemonManager.getAllStatus() {
+				if name == "timestamp" || name == "uptime" {
+					continue
+				}
+				info, ok := status.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				event := &tunnelpb.StatusEvent{
+					Process:       name,
+					Running:       info["running"] == true,
+					UptimeHuman:   formatDuration(uptime),
+					UptimeSeconds: int64(uptime.Seconds()),
+					TunnelType:    string(tunnelType),
+					TunnelDomain:  tunnelDomain,
+				}
+				if err := stream.Send(event); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (s *tunnelControlServer) Analyze(ctx context.Context, req *tunnelpb.AnalyzeRequest) (*tunnelpb.AnalyzeResponse, error) {
+	analyzer := NewServer(&Config{ArgoAuth: req.ArgoAuth})
+	return &tunnelpb.AnalyzeResponse{TunnelType: string(analyzer.analyzeTunnelType())}, nil
+}
+
+func (s *tunnelControlServer) Start(ctx context.Context, req *tunnelpb.StartRequest) (*tunnelpb.ActionResponse, error) {
+	go s.daemonManager.scheduleRestart(req.Process, "", nil)
+	return &tunnelpb.ActionResponse{Success: true, Message: fmt.Sprintf("%s start requested", req.Process)}, nil
+}
+
+func (s *tunnelControlServer) Stop(ctx context.Context, req *tunnelpb.StopRequest) (*tunnelpb.ActionResponse, error) {
+	s.daemonManager.mu.RLock()
+	cmd, ok := s.daemonManager.processes[req.Process]
+	s.daemonManager.mu.RUnlock()
+
+	if !ok || cmd == nil || cmd.Process == nil {
+		return &tunnelpb.ActionResponse{Success: false, Message: fmt.Sprintf("%s is not running", req.Process)}, nil
+	}
+
+	if err := cmd.Process.Kill(); err != nil {
+		return nil, err
+	}
+	return &tunnelpb.ActionResponse{Success: true, Message: fmt.Sprintf("%s stopped", req.Process)}, nil
+}
+
+func (s *tunnelControlServer) Restart(ctx context.Context, req *tunnelpb.RestartRequest) (*tunnelpb.ActionResponse, error) {
+	go s.daemonManager.scheduleRestart(req.Process, "", nil)
+	return &tunnelpb.ActionResponse{Success: true, Message: fmt.Sprintf("%s restart requested", req.Process)}, nil
+}
+
+func (s *tunnelControlServer) RotateCredentials(ctx context.Context, req *tunnelpb.RotateCredentialsRequest) (*tunnelpb.ActionResponse, error) {
+	cfg := configStore.Load()
+	if cfg.QuickTunnelCreds == nil {
+		return &tunnelpb.ActionResponse{Success: false, Message: "no quick tunnel credentials to rotate"}, nil
+	}
+
+	old := cfg.QuickTunnelCreds
+	creds, err := provisionQuickTunnel(generateRandomName(8))
+	if err != nil {
+		return nil, err
+	}
+	updated := *cfg
+	updated.QuickTunnelCreds = creds
+	configStore.Store(&updated)
+	deprovisionQuickTunnel(old)
+
+	go s.daemonManager.scheduleRestart("tunnel", "", nil)
+	return &tunnelpb.ActionResponse{Success: true, Message: fmt.Sprintf("rotated to tunnel %s", creds.TunnelID)}, nil
+}
+
+func (s *tunnelControlServer) SwitchTunnelType(ctx context.Context, req *tunnelpb.SwitchTunnelTypeRequest) (*tunnelpb.ActionResponse, error) {
+	tunnelType := TunnelType(req.TunnelType)
+	if err := prepareTunnelConfig(tunnelType); err != nil {
+		return nil, err
+	}
+	s.daemonManager.setTunnelInfo(tunnelType, s.daemonManager.status.Tunnel.Domain)
+	go s.daemonManager.scheduleRestart("tunnel", "", nil)
+	return &tunnelpb.ActionResponse{Success: true, Message: fmt.Sprintf("switched to %s", tunnelType)}, nil
+}
@@ -0,0 +1,291 @@
+// Package nezha 实现一个进程内的Nezha v1监控agent，使daemon不必再为了上报
+// 主机指标而下载、chmod一个外部的"nezha-agent"二进制。
+package nezha
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"argo-go/api/nezhapb"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+	netstat "github.com/shirou/gopsutil/v3/net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// Config配置一个内嵌的Nezha v1 agent。
+type Config struct {
+	Server       string // host:port，例如"nezha.example.com:443"
+	ClientSecret string // NEZHA_KEY
+	ClientUUID   string // 作为agent的ClientUUID上报
+
+	ReportInterval time.Duration // State上报间隔；默认2s
+	HostInterval   time.Duration // Host上报间隔；默认10m
+}
+
+// Agent是一个内嵌的Nezha v1 agent：通过gRPC拨通Config.Server，用nezhapb文档
+// 描述的client_uuid/client_secret元数据对做认证，并发送用gopsutil采集的
+// State/Host上报。nezhapb的schema是根据公开文档反推出来的，没有对照
+// nezha-agent真正的上游.proto验证过（见nezha.proto），所以只有在确认
+// NEZHA_SERVER说的是完全一样的schema时，这里的上报才能正常工作。和旧的
+// 下载二进制方案不同，Healthy反映的是gRPC流的健康状态而不是操作系统进程的
+// 存活状态，所以checkProcessHealth直接调用它而不是给一个PID发信号。
+type Agent struct {
+	cfg Config
+
+	mu        sync.RWMutex
+	healthy   bool
+	lastError error
+
+	prevNetIn, prevNetOut uint64
+	prevSampledAt         time.Time
+}
+
+// New构建一个Agent，并补上ReportInterval/HostInterval的默认值。
+func New(cfg Config) *Agent {
+	if cfg.ReportInterval <= 0 {
+		cfg.ReportInterval = 2 * time.Second
+	}
+	if cfg.HostInterval <= 0 {
+		cfg.HostInterval = 10 * time.Minute
+	}
+	return &Agent{cfg: cfg}
+}
+
+// Healthy报告agent当前是否有一条到Config.Server、已通过认证的存活上报流。
+func (a *Agent) Healthy() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.healthy
+}
+
+func (a *Agent) setHealthy(healthy bool, err error) {
+	a.mu.Lock()
+	a.healthy = healthy
+	a.lastError = err
+	a.mu.Unlock()
+}
+
+// useTLS按旧agent二进制--tls参数同样的方式自动判断是否启用TLS：信任那些
+// 广为人知的Cloudflare/Nezha TLS端口，所以NEZHA_SERVER后面直接跟个":443"
+// （NEZHA_PORT留空时的常见情况）就足以启用它。
+func useTLS(server string) bool {
+	parts := strings.Split(server, ":")
+	if len(parts) < 2 {
+		return false
+	}
+	switch parts[len(parts)-1] {
+	case "443", "8443", "2096", "2087", "2083", "2053":
+		return true
+	default:
+		return false
+	}
+}
+
+// Start拨通Config.Server，运行上报循环直到ctx被取消；流出错时按固定的
+// backoff重连，使NEZHA_SERVER重启不需要连带重启argo-go。
+func (a *Agent) Start(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := a.runOnce(ctx); err != nil {
+			a.setHealthy(false, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (a *Agent) runOnce(ctx context.Context) error {
+	var creds credentials.TransportCredentials
+	if useTLS(a.cfg.Server) {
+		creds = credentials.NewTLS(&tls.Config{})
+	} else {
+		creds = insecure.NewCredentials()
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(dialCtx, a.cfg.Server, grpc.WithTransportCredentials(creds), grpc.WithBlock())
+	if err != nil {
+		return fmt.Errorf("dial nezha server: %w", err)
+	}
+	defer conn.Close()
+
+	client := nezhapb.NewNezhaServiceClient(conn)
+	authedCtx := a.authContext(ctx)
+
+	if err := a.reportHost(authedCtx, client); err != nil {
+		return fmt.Errorf("report host: %w", err)
+	}
+
+	stream, err := client.ReportSystemState(authedCtx)
+	if err != nil {
+		return fmt.Errorf("open state stream: %w", err)
+	}
+
+	a.setHealthy(true, nil)
+
+	stateTicker := time.NewTicker(a.cfg.ReportInterval)
+	defer stateTicker.Stop()
+	hostTicker := time.NewTicker(a.cfg.HostInterval)
+	defer hostTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hostTicker.C:
+			if err := a.reportHost(authedCtx, client); err != nil {
+				return fmt.Errorf("report host: %w", err)
+			}
+		case <-stateTicker.C:
+			state, err := a.collectState()
+			if err != nil {
+				return fmt.Errorf("collect state: %w", err)
+			}
+			if err := stream.Send(state); err != nil {
+				return fmt.Errorf("send state: %w", err)
+			}
+		}
+	}
+}
+
+// authContext附上v1协议的client_uuid/client_secret元数据对，是旧agent二进制
+// "-p <secret>"参数和config.yaml里uuid字段在gRPC下的对应物。
+func (a *Agent) authContext(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "client_uuid", a.cfg.ClientUUID, "client_secret", a.cfg.ClientSecret)
+}
+
+func (a *Agent) reportHost(ctx context.Context, client nezhapb.NezhaServiceClient) error {
+	h, err := a.collectHost()
+	if err != nil {
+		return err
+	}
+	_, err = client.ReportSystemInfo(ctx, h)
+	return err
+}
+
+func (a *Agent) collectHost() (*nezhapb.Host, error) {
+	info, err := host.Info()
+	if err != nil {
+		return nil, err
+	}
+
+	var cpuModel string
+	if cpuInfo, err := cpu.Info(); err == nil && len(cpuInfo) > 0 {
+		cpuModel = cpuInfo[0].ModelName
+	}
+
+	memStat, err := mem.VirtualMemory()
+	if err != nil {
+		return nil, err
+	}
+	swapStat, _ := mem.SwapMemory()
+
+	diskUsage, err := disk.Usage("/")
+	if err != nil {
+		return nil, err
+	}
+
+	return &nezhapb.Host{
+		Platform:        info.Platform,
+		PlatformVersion: info.PlatformVersion,
+		CpuInfo:         cpuModel,
+		MemTotal:        memStat.Total,
+		DiskTotal:       diskUsage.Total,
+		SwapTotal:       swapStat.Total,
+		Arch:            info.KernelArch,
+		Virtualization:  info.VirtualizationSystem,
+		BootTime:        int64(info.BootTime),
+		Version:         "argo-go-nezha/1",
+	}, nil
+}
+
+func (a *Agent) collectState() (*nezhapb.State, error) {
+	cpuPercent, err := cpu.Percent(0, false)
+	if err != nil {
+		return nil, err
+	}
+	var cpuUsed float64
+	if len(cpuPercent) > 0 {
+		cpuUsed = cpuPercent[0]
+	}
+
+	memStat, err := mem.VirtualMemory()
+	if err != nil {
+		return nil, err
+	}
+	swapStat, _ := mem.SwapMemory()
+
+	diskUsage, err := disk.Usage("/")
+	if err != nil {
+		return nil, err
+	}
+
+	netIn, netOut, err := a.netTotals()
+	if err != nil {
+		return nil, err
+	}
+
+	elapsed := time.Since(a.prevSampledAt).Seconds()
+	var inSpeed, outSpeed float64
+	if !a.prevSampledAt.IsZero() && elapsed > 0 {
+		inSpeed = float64(netIn-a.prevNetIn) / elapsed
+		outSpeed = float64(netOut-a.prevNetOut) / elapsed
+	}
+	a.prevNetIn, a.prevNetOut, a.prevSampledAt = netIn, netOut, time.Now()
+
+	tcpConns, _ := netstat.Connections("tcp")
+	udpConns, _ := netstat.Connections("udp")
+
+	state := &nezhapb.State{
+		Cpu:            cpuUsed,
+		MemoryUsed:     float64(memStat.Used),
+		SwapUsed:       float64(swapStat.Used),
+		DiskUsed:       float64(diskUsage.Used),
+		NetInTransfer:  float64(netIn),
+		NetOutTransfer: float64(netOut),
+		NetInSpeed:     inSpeed,
+		NetOutSpeed:    outSpeed,
+		TcpConnCount:   uint64(len(tcpConns)),
+		UdpConnCount:   uint64(len(udpConns)),
+	}
+
+	if info, err := host.Info(); err == nil {
+		state.Uptime = info.Uptime
+		state.ProcessCount = info.Procs
+	}
+	if loadStat, err := load.Avg(); err == nil {
+		state.Load1 = loadStat.Load1
+		state.Load5 = loadStat.Load5
+		state.Load15 = loadStat.Load15
+	}
+
+	return state, nil
+}
+
+func (a *Agent) netTotals() (in, out uint64, err error) {
+	counters, err := netstat.IOCounters(false)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(counters) == 0 {
+		return 0, 0, nil
+	}
+	return counters[0].BytesRecv, counters[0].BytesSent, nil
+}
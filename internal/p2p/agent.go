@@ -0,0 +1,406 @@
+// Package p2p 实现一个WireGuard风格的加密点对点隧道，用作cloudflared edge
+// 不可达时的兜底方案：通过STUN给一个QUIC listener打洞，把UUID+pubkey发布到
+// 集合点服务器，只有在对端证明自己持有集合点服务器撮合给它的pubkey对应的
+// 私钥之后，才把入站的已加密VLESS流转发给本地Xray inbound。
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/stun"
+	"github.com/quic-go/quic-go"
+	"golang.org/x/crypto/curve25519"
+)
+
+// Config配置一个Agent。
+type Config struct {
+	Rendezvous string // P2P_RENDEZVOUS，例如"https://rendezvous.example.com"
+	NodeUUID   string // 和pubkey一起发布，供集合点服务器识别这个节点
+	ForwardTo  string // 入站流转发到的本地地址；默认"127.0.0.1:3001"
+	STUNServer string // 默认"stun.l.google.com:19302"
+
+	RegisterEvery time.Duration // 重新注册的周期；默认30s
+}
+
+// Agent持有一个已打洞的QUIC listener，接受单个经集合点撮合的peer发来的加密
+// VLESS流并转发给ForwardTo。DaemonManager的recordEdgeFailure发现连续足够多
+// 次cloudflared edge失败后，由startP2PTunnel把它拉起来。
+type Agent struct {
+	cfg Config
+
+	privKey [32]byte
+	pubKey  [32]byte
+
+	mu        sync.RWMutex
+	healthy   bool
+	peerID    string
+	peerPub   [32]byte
+	havePeer  bool
+	lastErr   error
+	localAddr *net.UDPAddr
+
+	// conn由Register/Serve两者中先跑的那个打开一次，之后STUN探测和QUIC
+	// listener都复用这同一个socket，使发布给集合点服务器的地址，永远就是
+	// peer实际连接的那个socket。
+	conn     *net.UDPConn
+	listener *quic.Listener
+}
+
+// New构建一个Agent及其临时X25519密钥对，并补上ForwardTo/STUNServer/
+// RegisterEvery的默认值。
+func New(cfg Config) *Agent {
+	if cfg.ForwardTo == "" {
+		cfg.ForwardTo = "127.0.0.1:3001"
+	}
+	if cfg.STUNServer == "" {
+		cfg.STUNServer = "stun.l.google.com:19302"
+	}
+	if cfg.RegisterEvery <= 0 {
+		cfg.RegisterEvery = 30 * time.Second
+	}
+
+	a := &Agent{cfg: cfg}
+	if _, err := rand.Read(a.privKey[:]); err == nil {
+		curve25519.ScalarBaseMult(&a.pubKey, &a.privKey)
+	}
+	return a
+}
+
+// Healthy报告agent当前是否有一个存活的、已向集合点服务器注册的QUIC listener。
+func (a *Agent) Healthy() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.healthy
+}
+
+// PeerID返回集合点服务器在最近一次成功的Register调用中分配的peer
+// id，用于拼出setTunnelInfo记录的peer-id.rendezvous主机名。
+func (a *Agent) PeerID() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.peerID
+}
+
+// ensureConn第一次调用时打开a.conn并返回；之后的调用方（Register的重新注册
+// tick、Serve）拿到的都是同一个socket，使Agent生命周期内始终只有一个UDP
+// socket。
+func (a *Agent) ensureConn() (*net.UDPConn, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conn != nil {
+		return a.conn, nil
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: 0})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open udp socket: %v", err)
+	}
+	a.conn = conn
+	return conn, nil
+}
+
+// publicAddr通过STUN解析本节点的server-reflexive地址，供集合点服务器转交
+// 给尝试打洞进来的peer。它始终通过ensureConn的socket探测——就是Serve之后
+// 交给quic.Listen的那一个——使发布出去的地址和实际监听的listener一致。一旦
+// listener已经起来，这个socket的读循环就归quic-go所有，这时再做一次STUN
+// 探测会和它抢读，所以之后的重新注册只是republish上一次解析出来的地址，
+// 不会再探测一次。
+func (a *Agent) publicAddr() (*net.UDPAddr, error) {
+	a.mu.RLock()
+	listening := a.listener != nil
+	cached := a.localAddr
+	a.mu.RUnlock()
+	if listening {
+		if cached == nil {
+			return nil, fmt.Errorf("no public address resolved yet")
+		}
+		return cached, nil
+	}
+
+	conn, err := a.ensureConn()
+	if err != nil {
+		return nil, err
+	}
+
+	stunAddr, err := net.ResolveUDPAddr("udp4", a.cfg.STUNServer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve stun server: %v", err)
+	}
+
+	message := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	if _, err := conn.WriteTo(message.Raw, stunAddr); err != nil {
+		return nil, fmt.Errorf("failed to send stun binding request: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1500)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stun response: %v", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	res := &stun.Message{Raw: buf[:n]}
+	if err := res.Decode(); err != nil {
+		return nil, fmt.Errorf("failed to decode stun response: %v", err)
+	}
+
+	var xorAddr stun.XORMappedAddress
+	if err := xorAddr.GetFrom(res); err != nil {
+		return nil, fmt.Errorf("failed to read xor-mapped address: %v", err)
+	}
+
+	addr := &net.UDPAddr{IP: xorAddr.IP, Port: xorAddr.Port}
+	a.mu.Lock()
+	a.localAddr = addr
+	a.mu.Unlock()
+	return addr, nil
+}
+
+// registration是Register发给集合点服务器、以及从它那收到的payload。
+// PeerPubKey在服务器已经把本节点和另一节点撮合成功时，是对方的X25519
+// pubkey；handleConn用它推导共享密钥，在转发之前先认证入站流。
+type registration struct {
+	UUID       string `json:"uuid"`
+	PubKey     string `json:"pubkey"`
+	Addr       string `json:"addr"`
+	PeerID     string `json:"peer_id"`
+	PeerPubKey string `json:"peer_pubkey,omitempty"`
+}
+
+// Register通过STUN解析本节点的公网地址，把UUID+pubkey+地址发布到
+// Config.Rendezvous，返回服务器分配的peer id。
+func (a *Agent) Register(ctx context.Context) (string, error) {
+	addr, err := a.publicAddr()
+	if err != nil {
+		return "", err
+	}
+
+	body, _ := json.Marshal(registration{
+		UUID:   a.cfg.NodeUUID,
+		PubKey: base64.StdEncoding.EncodeToString(a.pubKey[:]),
+		Addr:   addr.String(),
+	})
+
+	url := strings.TrimRight(a.cfg.Rendezvous, "/") + "/register"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(string(body)))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach rendezvous server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("rendezvous server returned status %d", resp.StatusCode)
+	}
+
+	var reg registration
+	if err := json.NewDecoder(resp.Body).Decode(&reg); err != nil {
+		return "", fmt.Errorf("failed to decode rendezvous response: %v", err)
+	}
+	if reg.PeerID == "" {
+		return "", fmt.Errorf("rendezvous server did not assign a peer id")
+	}
+
+	a.mu.Lock()
+	a.peerID = reg.PeerID
+	if reg.PeerPubKey != "" {
+		if peerPub, err := base64.StdEncoding.DecodeString(reg.PeerPubKey); err == nil && len(peerPub) == 32 {
+			copy(a.peerPub[:], peerPub)
+			a.havePeer = true
+		}
+	}
+	a.mu.Unlock()
+
+	return reg.PeerID, nil
+}
+
+// Serve接受打洞进来的QUIC连接，把每条入站流已加密的VLESS payload转发给
+// Config.ForwardTo，直到ctx被取消。它每隔RegisterEvery向集合点服务器重新
+// 注册一次，使公网地址发生变化（例如NAT rebind之后）时能保持最新。listener
+// 绑定的就是publicAddr（经由ensureConn）探测过的那个socket——不管这次探测
+// 是在这里发生的，还是已经在更早的Register调用里跑过了——所以交给集合点
+// 服务器的地址，始终是peer真正能连上的那个。
+func (a *Agent) Serve(ctx context.Context) {
+	conn, err := a.ensureConn()
+	if err != nil {
+		a.mu.Lock()
+		a.healthy, a.lastErr = false, err
+		a.mu.Unlock()
+		return
+	}
+	if _, err := a.publicAddr(); err != nil {
+		a.mu.Lock()
+		a.healthy, a.lastErr = false, err
+		a.mu.Unlock()
+		return
+	}
+
+	tlsConf, err := selfSignedTLSConfig()
+	if err != nil {
+		a.mu.Lock()
+		a.healthy, a.lastErr = false, err
+		a.mu.Unlock()
+		return
+	}
+
+	listener, err := quic.Listen(conn, tlsConf, nil)
+	if err != nil {
+		a.mu.Lock()
+		a.healthy, a.lastErr = false, err
+		a.mu.Unlock()
+		return
+	}
+	a.mu.Lock()
+	a.listener = listener
+	a.healthy = true
+	a.mu.Unlock()
+
+	ticker := time.NewTicker(a.cfg.RegisterEvery)
+	defer ticker.Stop()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := a.Register(ctx); err != nil {
+					a.mu.Lock()
+					a.lastErr = err
+					a.mu.Unlock()
+				}
+			}
+		}
+	}()
+
+	for {
+		conn, err := listener.Accept(ctx)
+		if err != nil {
+			a.mu.Lock()
+			a.healthy, a.lastErr = false, err
+			a.mu.Unlock()
+			return
+		}
+		go a.handleConn(ctx, conn)
+	}
+}
+
+func (a *Agent) handleConn(ctx context.Context, conn quic.Connection) {
+	a.mu.RLock()
+	peerPub, havePeer := a.peerPub, a.havePeer
+	a.mu.RUnlock()
+	if !havePeer {
+		conn.CloseWithError(0, "no matched peer pubkey to authenticate against")
+		return
+	}
+
+	for {
+		stream, err := conn.AcceptStream(ctx)
+		if err != nil {
+			return
+		}
+		go a.authenticateAndForward(stream, peerPub)
+	}
+}
+
+// authenticateAndForward在转发任何字节给ForwardTo之前，要求发送方证明自己
+// 持有与peerPub（集合点服务器为本节点撮合的peer的pubkey）匹配的私钥。没有
+// 这一步，任何能连到这个打洞端口的主机（而不仅仅是撮合到的peer）都会被
+// 直接中继到本地Xray inbound上。
+func (a *Agent) authenticateAndForward(stream quic.Stream, peerPub [32]byte) {
+	shared, err := curve25519.X25519(a.privKey[:], peerPub[:])
+	if err != nil {
+		stream.Close()
+		return
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		stream.Close()
+		return
+	}
+
+	stream.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := stream.Write(nonce); err != nil {
+		stream.Close()
+		return
+	}
+
+	mac := hmac.New(sha256.New, shared)
+	mac.Write(nonce)
+	want := mac.Sum(nil)
+
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(stream, got); err != nil {
+		stream.Close()
+		return
+	}
+	stream.SetDeadline(time.Time{})
+
+	if !hmac.Equal(got, want) {
+		stream.Close()
+		return
+	}
+
+	forwardStream(stream, a.cfg.ForwardTo)
+}
+
+// forwardStream把一条已认证的入站QUIC流原样双向转发给本地Xray VLESS
+// inbound；打洞链路的加密已经由QUIC的TLS 1.3传输层提供。
+func forwardStream(stream quic.Stream, forwardTo string) {
+	defer stream.Close()
+
+	local, err := net.Dial("tcp", forwardTo)
+	if err != nil {
+		return
+	}
+	defer local.Close()
+
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(local, stream); done <- struct{}{} }()
+	go func() { io.Copy(stream, local); done <- struct{}{} }()
+	<-done
+}
+
+// selfSignedTLSConfig构建quic-go接受连接所需的临时TLS配置；它本身不能证明
+// 连接进来的peer的任何身份。真正认证一条流的是authenticateAndForward，
+// 通过一个以集合点撮合的pubkey交换所推导出的X25519共享密钥为key的HMAC
+// 挑战。
+func selfSignedTLSConfig() (*tls.Config, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{SerialNumber: big.NewInt(1)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"argo-go-p2p"},
+	}, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"argo-go/backend/kubernetes"
+
+	k8sclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// startKubernetesOperator在Config.KubernetesMode开启时运行operator协调循环，
+// 取代单隧道的startAllServices路径。每个Tunnel CR各自对应一个Server，复用
+// analyzeTunnelType、generateRandomName和formatDuration，使CR上报的状态与
+// daemon对等价的ArgoAuth/Config会记录的日志保持一致。
+func startKubernetesOperator(cfg *Config) error {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load in-cluster config: %v", err)
+	}
+
+	clientset, err := k8sclient.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to build kubernetes client: %v", err)
+	}
+
+	servers := map[string]*DaemonManager{}
+
+	controller := kubernetes.NewController(
+		clientset,
+		func(argoAuth string) string {
+			return string(NewServer(&Config{ArgoAuth: argoAuth}).analyzeTunnelType())
+		},
+		generateRandomName,
+		formatDuration,
+		func(ctx context.Context, t *kubernetes.Tunnel) error {
+			return reconcileTunnelCR(cfg, servers, t)
+		},
+	)
+	controller.LeaseName = "argo-go-operator"
+	controller.LeaseNS = "default"
+	controller.Identity = generateRandomName(8)
+
+	go func() {
+		if err := controller.ServeProbes(":8081"); err != nil {
+			eventLogger.Error("operator probe server stopped", "event", "k8s_operator", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// reconcileTunnelCR在第一次见到某个Tunnel CR时，为它生成一对按CR名字索引的
+// Config/DaemonManager。
+func reconcileTunnelCR(base *Config, servers map[string]*DaemonManager, t *kubernetes.Tunnel) error {
+	if _, ok := servers[t.Name]; ok {
+		return nil
+	}
+
+	tunnelCfg := *base
+	tunnelCfg.ArgoAuth = t.Spec.ArgoAuth
+	tunnelCfg.ArgoDomain = t.Spec.Hostname
+	tunnelCfg.Name = t.Spec.Name
+
+	dm := NewDaemonManager(NewConfigStore(&tunnelCfg))
+	servers[t.Name] = dm
+
+	eventLogger.Info("reconciled tunnel CR", "namespace", t.Namespace, "name", t.Name, "service", t.Spec.Service, "event", "k8s_operator")
+	return nil
+}
@@ -4,12 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"crypto/rand"
+	cryptorand "crypto/rand"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math/rand"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -17,47 +18,191 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"argo-go/cfoptimizer"
+	"argo-go/internal/nezha"
 )
 
+// tunnelDomainRegex extracts a trycloudflare.com hostname from a cloudflared
+// log line regardless of surrounding ANSI codes/timestamps; used in place of
+// the old strings.Split(line, "trycloudflare.com") which mis-parsed lines
+// with more than one occurrence of the suffix.
+var tunnelDomainRegex = regexp.MustCompile(`https?://([a-z0-9-]+\.trycloudflare\.com)`)
+
+// cloudflaredColoRegex pulls the edge colo code cloudflared reports when a
+// tunnel connection registers, e.g. `...registered connIndex=0 location=SJC`,
+// mirroring the location=<colo> field cloudflared itself logs on connect.
+var cloudflaredColoRegex = regexp.MustCompile(`location=([A-Za-z0-9]+)`)
+
 // Config 配置结构
 type Config struct {
-	UploadURL      string `json:"UPLOAD_URL"`
-	ProjectURL     string `json:"PROJECT_URL"`
-	AutoAccess     bool   `json:"AUTO_ACCESS"`
-	FilePath       string `json:"FILE_PATH"`
-	SubPath        string `json:"SUB_PATH"`
-	Port           int    `json:"SERVER_PORT"`
-	ExternalPort   int    `json:"EXTERNAL_PORT"`
-	UUID           string `json:"UUID"`
-	NezhaServer    string `json:"NEZHA_SERVER"`
-	NezhaPort      string `json:"NEZHA_PORT"`
-	NezhaKey       string `json:"NEZHA_KEY"`
-	ArgoDomain     string `json:"ARGO_DOMAIN"`
-	ArgoAuth       string `json:"ARGO_AUTH"`
-	ArgoPort       int    `json:"ARGO_PORT"`
-	CFIP           string `json:"CFIP"`
-	CFPort         int    `json:"CFPORT"`
-	Name           string `json:"NAME"`
-	
+	UploadURL    string `json:"UPLOAD_URL"`
+	ProjectURL   string `json:"PROJECT_URL"`
+	AutoAccess   bool   `json:"AUTO_ACCESS"`
+	FilePath     string `json:"FILE_PATH"`
+	SubPath      string `json:"SUB_PATH"`
+	Port         int    `json:"SERVER_PORT"`
+	ExternalPort int    `json:"EXTERNAL_PORT"`
+	UUID         string `json:"UUID"`
+	NezhaServer  string `json:"NEZHA_SERVER"`
+	NezhaPort    string `json:"NEZHA_PORT"`
+	NezhaKey     string `json:"NEZHA_KEY"`
+	ArgoDomain   string `json:"ARGO_DOMAIN"`
+	ArgoAuth     string `json:"ARGO_AUTH"`
+	ArgoPort     int    `json:"ARGO_PORT"`
+	CFIP         string `json:"CFIP"`
+	CFPort       int    `json:"CFPORT"`
+	Name         string `json:"NAME"`
+
+	// QuickTunnelCreds 运行时由快速隧道（命名隧道后端）provisioning 流程填充，不从环境变量读取
+	QuickTunnelCreds *QuickTunnelCredentials `json:"-"`
+
+	// GRPCListen gRPC控制面监听地址，例如":9090"；留空则不启动gRPC服务
+	GRPCListen string `json:"GRPC_LISTEN"`
+
+	// WSAuthToken 访问WebSocket控制端点/ws所需的令牌，留空则不做鉴权
+	WSAuthToken string `json:"WS_AUTH_TOKEN"`
+
+	// KubernetesMode 为true时以operator模式运行，按Tunnel CRD逐个reconcile隧道，
+	// 而不是启动startAllServices里的单一隧道
+	KubernetesMode bool `json:"KUBERNETES_MODE"`
+
+	// MetricsAddr Prometheus /metrics与状态面板的监听地址，例如":9091"；留空则不启动
+	MetricsAddr string `json:"METRICS_ADDR"`
+
+	// AssetManifestURL 资产清单地址（http(s) URL或本地文件路径），用于校验二进制下载；
+	// 留空则沿用旧的直接下载逻辑。也可通过MANIFEST_URL设置（等价别名，与cloudflared
+	// 等上游发行版常用的命名保持一致）
+	AssetManifestURL string `json:"ASSET_MANIFEST_URL"`
+
+	// AssetPubKey 校验清单中资产签名所用的base64 ed25519公钥，留空则跳过签名校验
+	AssetPubKey string `json:"ASSET_PUBKEY"`
+
+	// LogFormat 守护进程事件日志格式，"json"或"text"；默认"text"
+	LogFormat string `json:"LOG_FORMAT"`
+
+	// LogLevel 最低输出级别："debug"|"info"|"warn"|"error"，默认"info"
+	LogLevel string `json:"LOG_LEVEL"`
+
+	// LogFilePath 轮转日志文件路径，留空则默认FilePath/cloudflared.log；
+	// FilePath本身为空（测试环境）时不写文件，只输出到stdout
+	LogFilePath string `json:"LOG_FILE_PATH"`
+
+	// LogMaxSizeMB/LogMaxAgeDays 控制rotatingFileWriter的轮转阈值：单文件超过
+	// LogMaxSizeMB即轮转，轮转后的旧文件保留LogMaxAgeDays天后自动删除；默认50/7
+	LogMaxSizeMB  int `json:"LOG_MAX_SIZE_MB"`
+	LogMaxAgeDays int `json:"LOG_MAX_AGE_DAYS"`
+
+	// P2PRendezvous WireGuard风格P2P兜底隧道的集合点服务器地址；留空则禁用P2P兜底，
+	// handleTunnelOutput检测到cloudflared连续连不上edge时也不会触发切换
+	P2PRendezvous string `json:"P2P_RENDEZVOUS"`
+
+	// DomainExtractTimeoutMS extractDomainFromLogs等待boot.log里出现隧道域名的
+	// 总超时（毫秒），超时后放弃并记一条error日志；默认60000
+	DomainExtractTimeoutMS int `json:"DOMAIN_EXTRACT_TIMEOUT_MS"`
+
+	// TunnelProtocol cloudflared使用的传输协议："http2"（默认）、"quic"或"auto"；
+	// quic启动后若短时间内UDP会话注册失败，DaemonManager.handleQUICFallback会自动
+	// 回退到http2并重启隧道，详见effectiveTunnelProtocol
+	TunnelProtocol string `json:"TUNNEL_PROTOCOL"`
+
+	// CFAPIToken 调用Cloudflare官方Tunnel API（api.cloudflare.com/client/v4）为quick
+	// 隧道创建/回收具名隧道所需的令牌，需要Account:Cloudflare Tunnel:Edit权限；留空时
+	// provisionQuickTunnel直接报错，不再假装存在一个trycloudflare.com自助签发接口
+	CFAPIToken string `json:"CF_API_TOKEN"`
+
+	// CFAccountID CFAPIToken对应令牌可访问的Cloudflare账户ID
+	CFAccountID string `json:"CF_ACCOUNT_ID"`
+
+	// CFZoneID/QuickTunnelDomain 为quick隧道生成主机名所在的zone及其根域名：
+	// 留空时provisionQuickTunnel只创建隧道本身，不指派主机名（需要运维手动建DNS）；
+	// 都设置时自动在该zone下创建"<随机名>.<QuickTunnelDomain>"指向隧道的CNAME记录
+	CFZoneID          string `json:"CF_ZONE_ID"`
+	QuickTunnelDomain string `json:"QUICK_TUNNEL_DOMAIN"`
+
+	// CFIPAutoSelect 为true时启动cfoptimizer后台探测已知Cloudflare anycast IP段，
+	// 按TCP/TLS握手延迟排序，generateSubscription据此生成多节点订阅；为false（默认）
+	// 则沿用cfg.CFIP/cfg.CFPort单一固定节点，行为与之前一致
+	CFIPAutoSelect bool `json:"CFIP_AUTO_SELECT"`
+
+	// CFIPRefreshIntervalMin cfoptimizer重新探测并排序的间隔（分钟），默认30
+	CFIPRefreshIntervalMin int `json:"CFIP_REFRESH_INTERVAL_MIN"`
+
 	// 守护进程配置
 	DaemonCheckInterval int `json:"DAEMON_CHECK_INTERVAL"`
 	DaemonMaxRetries    int `json:"DAEMON_MAX_RETRIES"`
 	DaemonRestartDelay  int `json:"DAEMON_RESTART_DELAY"`
+
+	// DaemonMaxBackoff 熔断器打开期间退避延迟的上限（毫秒），默认10分钟
+	DaemonMaxBackoff int `json:"DAEMON_MAX_BACKOFF"`
+
+	// ConfigFile 可选的JSON/YAML配置文件路径，按reloadConfig叠加在环境变量之上；
+	// 留空则只使用环境变量，与之前行为一致
+	ConfigFile string `json:"-"`
+
+	// ConfigReloadToken 访问POST /config/reload所需的令牌，留空则不做鉴权
+	ConfigReloadToken string `json:"-"`
 }
 
 // TunnelType 隧道类型
 type TunnelType string
 
 const (
-	TunnelFixed     TunnelType = "fixed"
-	TunnelToken     TunnelType = "token"
-	TunnelTemporary TunnelType = "temporary"
+	TunnelTypeFixed TunnelType = "fixed"
+	TunnelTypeToken TunnelType = "token"
+	TunnelTypeQuick TunnelType = "quick"
+
+	// TunnelTypeP2P 是cloudflared edge连不上时的WireGuard风格QUIC兜底隧道，
+	// 由DaemonManager.recordEdgeFailure或POST /tunnel/switch触发，详见startP2PTunnel
+	TunnelTypeP2P TunnelType = "p2p"
+)
+
+// edgeFailureWindow/edgeFailureThreshold定义recordEdgeFailure判定"cloudflared反复连不上edge"
+// 的滑动窗口：60秒内5次"failed to connect to edge"即视为触发P2P兜底
+const (
+	edgeFailureWindow    = 60 * time.Second
+	edgeFailureThreshold = 5
+)
+
+// TunnelProtocol取值写入tunnel.yml的protocol字段（固定/快速隧道）或传给cloudflared
+// 的--protocol参数（token隧道）；TunnelProtocolAuto原样传给cloudflared，交由其自行探测。
+const (
+	TunnelProtocolHTTP2 = "http2"
+	TunnelProtocolQUIC  = "quic"
+	TunnelProtocolAuto  = "auto"
+)
+
+// quicFallbackWindow是handleQUICFallback判定"quic启动后很快就UDP会话注册失败"的窗口：
+// 启动quicFallbackWindow内出现该错误才会触发一次性回退到http2，避免运行中途的瞬时UDP
+// 问题也被当成协议不可用处理
+const quicFallbackWindow = 15 * time.Second
+
+// QuickTunnelCredentials 快速隧道凭证（由命名隧道后端在运行时签发，不落盘到环境变量）
+type QuickTunnelCredentials struct {
+	AccountTag   string `json:"AccountTag"`
+	TunnelSecret string `json:"TunnelSecret"`
+	TunnelID     string `json:"TunnelID"`
+	TunnelName   string `json:"TunnelName"`
+	Hostname     string `json:"hostname"`
+}
+
+// CircuitState 进程重启熔断器状态
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // 正常：失败按退避延迟重启
+	CircuitOpen     CircuitState = "open"      // 打开：连续失败次数过多，等待退避窗口过去
+	CircuitHalfOpen CircuitState = "half_open" // 半开：退避窗口已过，放行一次探测性重启
 )
 
 // ProcessStatus 进程状态
@@ -68,6 +213,11 @@ type ProcessStatus struct {
 	Pid       int       `json:"pid"`
 	Type      string    `json:"type,omitempty"`
 	Domain    string    `json:"domain,omitempty"`
+
+	// CircuitState/NextAttemptAt/OpenCount持久化熔断器状态，使其在loadStatus之后仍然生效
+	CircuitState  CircuitState `json:"circuitState,omitempty"`
+	NextAttemptAt time.Time    `json:"nextAttemptAt,omitempty"`
+	OpenCount     int          `json:"openCount,omitempty"`
 }
 
 // DaemonStatus 守护进程状态
@@ -77,6 +227,10 @@ type DaemonStatus struct {
 	Tunnel    struct {
 		Type   TunnelType `json:"type"`
 		Domain string     `json:"domain"`
+
+		// Colo/Region是从cloudflared"registered...location=<colo>"连接日志里
+		// 解析出的edge colo代码，由setTunnelEdge填充，留空表示尚未收到连接注册日志
+		Colo string `json:"colo,omitempty"`
 	} `json:"tunnel"`
 	Timestamp time.Time `json:"timestamp"`
 	Uptime    float64   `json:"uptime"`
@@ -84,7 +238,7 @@ type DaemonStatus struct {
 
 // DaemonManager 守护进程管理器
 type DaemonManager struct {
-	config        *Config
+	store         *ConfigStore
 	status        *DaemonStatus
 	processes     map[string]*exec.Cmd
 	checkTickers  map[string]*time.Ticker
@@ -92,32 +246,60 @@ type DaemonManager struct {
 	mu            sync.RWMutex
 	ctx           context.Context
 	cancel        context.CancelFunc
+
+	// edgeFailures记录最近的cloudflared"failed to connect to edge"时间戳，
+	// 由recordEdgeFailure维护；p2pFallbackTriggered避免同一次隧道运行内重复触发P2P兜底
+	edgeFailures         []time.Time
+	p2pFallbackTriggered bool
+
+	// tunnelProtocol是当前正在运行的cloudflared进程实际使用的协议，由
+	// startCloudflaredTunnel在每次启动时记录；forcedTunnelProtocol非空时
+	// effectiveTunnelProtocol优先返回它，用于handleQUICFallback强制下一次
+	// 重启改用http2；tunnelStartedAt配合quicFallbackWindow判断本次启动是否还在
+	// 窗口内；quicFallbackTriggered避免同一次隧道运行内重复回退
+	tunnelProtocol        string
+	forcedTunnelProtocol  string
+	tunnelStartedAt       time.Time
+	quicFallbackTriggered bool
 }
 
 // 全局变量
 var (
 	daemonManager *DaemonManager
-	config        *Config
-	randomNames   = struct {
-		npmName string
+
+	// configStore holds the live Config: NewConfig() seeds it at startup, and
+	// reloadConfig() atomically swaps it in on a valid SIGHUP/POST /config/reload
+	// reload. Everything that used to read the package-level config variable
+	// now calls configStore.Load() so it always sees the current snapshot.
+	configStore *ConfigStore
+
+	// nezhaAgent在startNezhaAgent拨通NEZHA_SERVER之前为nil；checkProcessHealth
+	// 对"nezha"这个key做了特殊处理，直接读取这里的gRPC流健康状态而不是检查PID，
+	// 因为已经没有子进程可以发信号了。
+	nezhaAgent *nezha.Agent
+
+	// eventLogger 承载结构化的守护进程事件（start/exit/restart），方便运维把
+	// 日志接入Loki/ELK；具体走JSON还是文本由newEventLogger按LOG_FORMAT决定。
+	// 未初始化时退回文本日志器，使不经过main()直接构造DaemonManager的测试
+	// 也能正常工作。
+	eventLogger = newEventLogger(&Config{LogFormat: "text"})
+
+	randomNames = struct {
 		webName string
 		botName string
-		phpName string
 	}{
-		npmName: generateRandomName(),
-		webName: generateRandomName(),
-		botName: generateRandomName(),
-		phpName: generateRandomName(),
+		webName: generateRandomName(6),
+		botName: generateRandomName(6),
 	}
 )
 
-// 生成随机6位字符文件名
-func generateRandomName() string {
+// 生成随机字符名称，长度由调用方指定
+func generateRandomName(length int) string {
 	const chars = "abcdefghijklmnopqrstuvwxyz"
-	result := make([]byte, 6)
-	for i := 0; i < 6; i++ {
+	result := make([]byte, length)
+	for i := 0; i < length; i++ {
 		b := make([]byte, 1)
-		_, err := rand.Read(b)
+		_, err := cryptorand.Read(b)
 		if err != nil {
 			result[i] = chars[i%len(chars)]
 		} else {
@@ -127,12 +309,34 @@ func generateRandomName() string {
 	return string(result)
 }
 
+// formatDuration 将时长格式化为"1小时1分钟1秒"这样的中文可读字符串
+func formatDuration(d time.Duration) string {
+	totalSeconds := int(d.Seconds())
+	hours := totalSeconds / 3600
+	minutes := (totalSeconds % 3600) / 60
+	seconds := totalSeconds % 60
+
+	var parts []string
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%d小时", hours))
+	}
+	if minutes > 0 {
+		parts = append(parts, fmt.Sprintf("%d分钟", minutes))
+	}
+	if seconds > 0 || len(parts) == 0 {
+		parts = append(parts, fmt.Sprintf("%d秒", seconds))
+	}
+	return strings.Join(parts, "")
+}
+
 // NewConfig 从环境变量创建配置
 func NewConfig() *Config {
 	port, _ := strconv.Atoi(getEnv("SERVER_PORT", "3000"))
 	externalPort, _ := strconv.Atoi(getEnv("EXTERNAL_PORT", "7860"))
 	autoAccess, _ := strconv.ParseBool(getEnv("AUTO_ACCESS", "false"))
-	
+	kubernetesMode, _ := strconv.ParseBool(getEnv("KUBERNETES_MODE", "false"))
+	cfipAutoSelect, _ := strconv.ParseBool(getEnv("CFIP_AUTO_SELECT", "false"))
+
 	cfg := &Config{
 		UploadURL:      getEnv("UPLOAD_URL", ""),
 		ProjectURL:     getEnv("PROJECT_URL", ""),
@@ -151,15 +355,225 @@ func NewConfig() *Config {
 		CFIP:           getEnv("CFIP", "cdns.doon.eu.org"),
 		CFPort:         getEnvInt("CFPORT", 443),
 		Name:           getEnv("NAME", ""),
-		
+		GRPCListen:     getEnv("GRPC_LISTEN", ""),
+		WSAuthToken:    getEnv("WS_AUTH_TOKEN", ""),
+		KubernetesMode: kubernetesMode,
+		MetricsAddr:    getEnv("METRICS_ADDR", ""),
+
+		AssetManifestURL: getEnv("ASSET_MANIFEST_URL", getEnv("MANIFEST_URL", "")),
+		TunnelProtocol:   getEnv("TUNNEL_PROTOCOL", TunnelProtocolHTTP2),
+		AssetPubKey:      getEnv("ASSET_PUBKEY", ""),
+
+		LogFormat:     getEnv("LOG_FORMAT", "text"),
+		LogLevel:      getEnv("LOG_LEVEL", "info"),
+		LogFilePath:   getEnv("LOG_FILE_PATH", ""),
+		LogMaxSizeMB:  getEnvInt("LOG_MAX_SIZE_MB", 50),
+		LogMaxAgeDays: getEnvInt("LOG_MAX_AGE_DAYS", 7),
+
+		P2PRendezvous: getEnv("P2P_RENDEZVOUS", ""),
+
+		DomainExtractTimeoutMS: getEnvInt("DOMAIN_EXTRACT_TIMEOUT_MS", 60000),
+
+		CFAPIToken:        getEnv("CF_API_TOKEN", ""),
+		CFAccountID:       getEnv("CF_ACCOUNT_ID", ""),
+		CFZoneID:          getEnv("CF_ZONE_ID", ""),
+		QuickTunnelDomain: getEnv("QUICK_TUNNEL_DOMAIN", ""),
+
+		CFIPAutoSelect:         cfipAutoSelect,
+		CFIPRefreshIntervalMin: getEnvInt("CFIP_REFRESH_INTERVAL_MIN", 30),
+
+		ConfigFile:        getEnv("CONFIG_FILE", ""),
+		ConfigReloadToken: getEnv("CONFIG_RELOAD_TOKEN", ""),
+
 		DaemonCheckInterval: getEnvInt("DAEMON_CHECK_INTERVAL", 30000),
 		DaemonMaxRetries:    getEnvInt("DAEMON_MAX_RETRIES", 5),
 		DaemonRestartDelay:  getEnvInt("DAEMON_RESTART_DELAY", 10000),
+		DaemonMaxBackoff:    getEnvInt("DAEMON_MAX_BACKOFF", 600000),
 	}
-	
+
 	return cfg
 }
 
+// logRingBuffer keeps the last N log lines in memory so handleDaemonStatus
+// can surface them over /daemon-status?logs=true without tailing the log
+// file or shelling out; safe for concurrent writes from the slog handler.
+type logRingBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	cap   int
+}
+
+func newLogRingBuffer(capacity int) *logRingBuffer {
+	return &logRingBuffer{cap: capacity}
+}
+
+func (b *logRingBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(b.lines, strings.TrimRight(string(p), "\n"))
+	if len(b.lines) > b.cap {
+		b.lines = b.lines[len(b.lines)-b.cap:]
+	}
+	return len(p), nil
+}
+
+func (b *logRingBuffer) snapshot() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]string, len(b.lines))
+	copy(out, b.lines)
+	return out
+}
+
+// recentLogLines returns the last lines written to the event logger, newest
+// last, for handleDaemonStatus's ?logs=true remote-debugging escape hatch.
+func recentLogLines() []string {
+	return logBuffer.snapshot()
+}
+
+// logBuffer backs recentLogLines; it is written to by every newEventLogger
+// handler regardless of LogFormat/LogFilePath so logs=true works even when
+// file logging is disabled (e.g. FilePath unset in tests).
+var logBuffer = newLogRingBuffer(200)
+
+// rotatingFileWriter is a size+age based log file sink: once the current
+// file exceeds maxSizeMB it is renamed with a timestamp suffix and a fresh
+// file opened in its place; rotated files older than maxAgeDays are pruned
+// on each rotation, so disk usage stays bounded without an external
+// logrotate configuration.
+type rotatingFileWriter struct {
+	mu         sync.Mutex
+	path       string
+	maxSizeMB  int
+	maxAgeDays int
+	file       *os.File
+	size       int64
+}
+
+func newRotatingFileWriter(path string, maxSizeMB, maxAgeDays int) (*rotatingFileWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &rotatingFileWriter{path: path, maxSizeMB: maxSizeMB, maxAgeDays: maxAgeDays, file: f, size: info.Size()}, nil
+}
+
+func (w *rotatingFileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.size+int64(len(p)) > int64(w.maxSizeMB)*1024*1024 {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotatingFileWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	rotated := fmt.Sprintf("%s.%s", w.path, time.Now().Format("20060102-150405"))
+	if err := os.Rename(w.path, rotated); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.size = 0
+	w.pruneOld()
+	return nil
+}
+
+// pruneOld removes rotated files (path.<timestamp>) older than maxAgeDays;
+// errors are swallowed since missing/unreadable rotated files shouldn't
+// block logging.
+func (w *rotatingFileWriter) pruneOld() {
+	matches, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -w.maxAgeDays)
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && info.ModTime().Before(cutoff) {
+			os.Remove(m)
+		}
+	}
+}
+
+// parseLogLevel maps cfg.LogLevel to a slog.Level, defaulting to Info for
+// an empty or unrecognized value.
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// newEventLogger builds the structured logger used across the daemon
+// (process lifecycle, tunnel/asset/subscription flows, etc). Output always
+// goes to stdout plus logBuffer (see recentLogLines); when cfg.FilePath is
+// set it's also teed to a size+age rotating file at cfg.LogFilePath
+// (default cfg.FilePath/cloudflared.log). Encoding is JSON or console text
+// per cfg.LogFormat, and cfg.LogLevel sets the minimum level emitted.
+func newEventLogger(cfg *Config) *slog.Logger {
+	writers := []io.Writer{os.Stdout, logBuffer}
+
+	if cfg.FilePath != "" {
+		logPath := cfg.LogFilePath
+		if logPath == "" {
+			logPath = filepath.Join(cfg.FilePath, "cloudflared.log")
+		}
+		maxSizeMB := cfg.LogMaxSizeMB
+		if maxSizeMB == 0 {
+			maxSizeMB = 50
+		}
+		maxAgeDays := cfg.LogMaxAgeDays
+		if maxAgeDays == 0 {
+			maxAgeDays = 7
+		}
+		if fw, err := newRotatingFileWriter(logPath, maxSizeMB, maxAgeDays); err == nil {
+			writers = append(writers, fw)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: parseLogLevel(cfg.LogLevel)}
+	w := io.MultiWriter(writers...)
+
+	var handler slog.Handler
+	if cfg.LogFormat == "json" {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(handler)
+}
+
+// fatal logs a structured error through eventLogger and exits, replacing
+// the stdlib log.Fatalf call sites this package used to have.
+func fatal(msg string, args ...any) {
+	eventLogger.Error(msg, args...)
+	os.Exit(1)
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -177,11 +591,11 @@ func getEnvInt(key string, defaultValue int) int {
 }
 
 // NewDaemonManager 创建守护进程管理器
-func NewDaemonManager(cfg *Config) *DaemonManager {
+func NewDaemonManager(store *ConfigStore) *DaemonManager {
 	ctx, cancel := context.WithCancel(context.Background())
-	
+
 	dm := &DaemonManager{
-		config:        cfg,
+		store:         store,
 		status:        &DaemonStatus{},
 		processes:     make(map[string]*exec.Cmd),
 		checkTickers:  make(map[string]*time.Ticker),
@@ -189,21 +603,28 @@ func NewDaemonManager(cfg *Config) *DaemonManager {
 		ctx:           ctx,
 		cancel:        cancel,
 	}
-	
+
 	dm.status.Processes = map[string]*ProcessStatus{
 		"nezha":  {Running: false},
 		"xray":   {Running: false},
 		"tunnel": {Running: false},
 	}
-	
+
 	// 加载保存的状态
 	dm.loadStatus()
-	
+
 	return dm
 }
 
+// cfg returns the daemon's current config snapshot; always go through this
+// instead of caching a *Config, so a reloadConfig() swap is picked up by the
+// very next call.
+func (dm *DaemonManager) cfg() *Config {
+	return dm.store.Load()
+}
+
 func (dm *DaemonManager) loadStatus() {
-	statusPath := filepath.Join(dm.config.FilePath, "daemon_status.json")
+	statusPath := filepath.Join(dm.cfg().FilePath, "daemon_status.json")
 	if _, err := os.Stat(statusPath); err == nil {
 		data, err := os.ReadFile(statusPath)
 		if err == nil {
@@ -215,56 +636,85 @@ func (dm *DaemonManager) loadStatus() {
 func (dm *DaemonManager) saveStatus() {
 	dm.status.mu.Lock()
 	defer dm.status.mu.Unlock()
-	
+
 	dm.status.Timestamp = time.Now()
 	dm.status.Uptime = time.Since(dm.status.Timestamp).Seconds()
-	
-	statusPath := filepath.Join(dm.config.FilePath, "daemon_status.json")
+
+	statusPath := filepath.Join(dm.cfg().FilePath, "daemon_status.json")
 	data, _ := json.MarshalIndent(dm.status, "", "  ")
 	os.WriteFile(statusPath, data, 0644)
 }
 
+// effectiveTunnelProtocol returns the protocol the next cloudflared start
+// should use: forcedTunnelProtocol (set by handleQUICFallback) takes
+// precedence over the configured TunnelProtocol for exactly one restart.
+func (dm *DaemonManager) effectiveTunnelProtocol(cfg *Config) string {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+	if dm.forcedTunnelProtocol != "" {
+		return dm.forcedTunnelProtocol
+	}
+	if cfg.TunnelProtocol == "" {
+		return TunnelProtocolHTTP2
+	}
+	return cfg.TunnelProtocol
+}
+
 func (dm *DaemonManager) setTunnelInfo(tunnelType TunnelType, domain string) {
 	dm.status.mu.Lock()
 	defer dm.status.mu.Unlock()
-	
+
 	dm.status.Tunnel.Type = tunnelType
 	dm.status.Tunnel.Domain = domain
-	
-	log.Printf("Tunnel type set to: %s, domain: %s", tunnelType, domain)
+
+	eventLogger.Info("tunnel info set", "tunnel_type", tunnelType, "domain", domain, "event", "tunnel_info")
 	dm.saveStatus()
 }
 
+// setTunnelEdge records the edge colo cloudflared connected to, parsed by
+// handleTunnelOutput from its connection-registration log line; it's purely
+// informational and surfaced read-only via getAllStatus/daemon-status.
+func (dm *DaemonManager) setTunnelEdge(colo string) {
+	dm.status.mu.Lock()
+	defer dm.status.mu.Unlock()
+
+	if dm.status.Tunnel.Colo == colo {
+		return
+	}
+	dm.status.Tunnel.Colo = colo
+	eventLogger.Info("tunnel edge colo detected", "colo", colo, "event", "tunnel_edge")
+}
+
 func (dm *DaemonManager) startProcess(name, command string, args []string) error {
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
-	
-	log.Printf("Starting %s process...", name)
-	
+
+	eventLogger.Info("starting process", "process", name, "event", "start")
+
 	cmd := exec.CommandContext(dm.ctx, command, args...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-	
+
 	// 设置输出管道
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		log.Printf("Failed to create stdout pipe for %s: %v", name, err)
+		eventLogger.Error("failed to create stdout pipe", "process", name, "event", "start_error", "error", err)
 		dm.scheduleRestart(name, command, args)
 		return err
 	}
-	
+
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		log.Printf("Failed to create stderr pipe for %s: %v", name, err)
+		eventLogger.Error("failed to create stderr pipe", "process", name, "event", "start_error", "error", err)
 		dm.scheduleRestart(name, command, args)
 		return err
 	}
-	
+
 	if err := cmd.Start(); err != nil {
-		log.Printf("Failed to start %s: %v", name, err)
+		eventLogger.Error("failed to start process", "process", name, "event", "start_error", "error", err)
 		dm.scheduleRestart(name, command, args)
 		return err
 	}
-	
+
 	dm.processes[name] = cmd
 	dm.status.Processes[name] = &ProcessStatus{
 		Running:   true,
@@ -272,18 +722,21 @@ func (dm *DaemonManager) startProcess(name, command string, args []string) error
 		LastStart: time.Now(),
 		Pid:       cmd.Process.Pid,
 	}
-	
+	if metricsCollector != nil {
+		metricsCollector.SetProcessUp(name, true)
+	}
+
 	// 处理输出
 	go dm.handleProcessOutput(name, stdout, stderr)
-	
+
 	// 监控进程退出
 	go dm.monitorProcess(name, cmd)
-	
+
 	// 启动健康检查
 	dm.startHealthCheck(name)
-	
+
 	dm.saveStatus()
-	
+
 	return nil
 }
 
@@ -295,33 +748,36 @@ func (dm *DaemonManager) handleProcessOutput(name string, stdout, stderr io.Read
 			line, err := reader.ReadString('\n')
 			if err != nil {
 				if err != io.EOF {
-					log.Printf("[%s] Error reading stdout: %v", name, err)
+					eventLogger.Error("error reading stdout", "process", name, "event", "stdout_error", "error", err)
 				}
 				break
 			}
 			line = strings.TrimSpace(line)
 			if line != "" {
-				log.Printf("[%s] %s", name, line)
-				
+				eventLogger.Info(line, "process", name, "event", "stdout")
+
 				if name == "tunnel" {
 					dm.handleTunnelOutput(line)
 				}
-				
-				if strings.Contains(line, "Connected") || 
-				   strings.Contains(line, "ready") || 
-				   strings.Contains(line, "started") || 
-				   strings.Contains(line, "listening") {
-					log.Printf("%s started successfully", name)
+
+				if strings.Contains(line, "Connected") ||
+					strings.Contains(line, "ready") ||
+					strings.Contains(line, "started") ||
+					strings.Contains(line, "listening") {
+					eventLogger.Info("process started successfully", "process", name, "event", "ready")
 					dm.mu.Lock()
 					if status, ok := dm.status.Processes[name]; ok {
 						status.Retries = 0
+						status.CircuitState = CircuitClosed
+						status.OpenCount = 0
+						status.NextAttemptAt = time.Time{}
 					}
 					dm.mu.Unlock()
 				}
 			}
 		}
 	}()
-	
+
 	// 处理标准错误
 	go func() {
 		reader := bufio.NewReader(stderr)
@@ -329,128 +785,271 @@ func (dm *DaemonManager) handleProcessOutput(name string, stdout, stderr io.Read
 			line, err := reader.ReadString('\n')
 			if err != nil {
 				if err != io.EOF {
-					log.Printf("[%s ERROR] Error reading stderr: %v", name, err)
+					eventLogger.Error("error reading stderr", "process", name, "event", "stderr_error", "error", err)
 				}
 				break
 			}
 			line = strings.TrimSpace(line)
 			if line != "" {
-				log.Printf("[%s ERROR] %s", name, line)
+				eventLogger.Warn(line, "process", name, "event", "stderr")
+
+				if name == "tunnel" {
+					dm.handleTunnelOutput(line)
+				}
 			}
 		}
 	}()
 }
 
 func (dm *DaemonManager) handleTunnelOutput(output string) {
+	// cloudflared连不上edge时打到stderr；反复出现则触发P2P兜底隧道
+	if strings.Contains(output, "failed to connect to edge") {
+		dm.recordEdgeFailure()
+	}
+
+	// quic传输下UDP会话注册失败是协议本身不可用的信号（而非单次边缘波动），
+	// 不等edgeFailureThreshold次数凑够就直接回退到http2
+	if strings.Contains(output, "udp session registration") {
+		dm.handleQUICFallback()
+	}
+
+	// cloudflared在连接注册成功时打出location=<colo>，与extractDomainFromLogs
+	// 解析的domain无关，纯粹用于在/daemon-status上报当前选用的edge colo
+	if m := cloudflaredColoRegex.FindStringSubmatch(output); m != nil {
+		dm.setTunnelEdge(m[1])
+	}
+
 	// 检查临时隧道的域名
-	if dm.status.Tunnel.Type == TunnelTemporary {
-		if strings.Contains(output, "trycloudflare.com") {
-			// 提取域名
-			replacer := strings.NewReplacer("https://", "", "http://", "")
-			parts := strings.Split(output, "trycloudflare.com")
-			if len(parts) > 0 {
-				domain := replacer.Replace(strings.TrimSpace(parts[0])) + "trycloudflare.com"
-				log.Printf("Temporary tunnel domain detected: %s", domain)
-				
-				dm.mu.Lock()
-				currentDomain := dm.status.Tunnel.Domain
-				dm.mu.Unlock()
-				
-				if currentDomain != domain {
-					dm.setTunnelInfo(TunnelTemporary, domain)
-					
-					// 触发订阅更新
-					go func() {
-						time.Sleep(2 * time.Second)
-						generateSubscription(domain)
-					}()
-				}
+	if dm.status.Tunnel.Type == TunnelTypeQuick {
+		if m := tunnelDomainRegex.FindStringSubmatch(output); m != nil {
+			domain := m[1]
+			eventLogger.Info("temporary tunnel domain detected", "domain", domain, "event", "domain_detected")
+
+			dm.mu.Lock()
+			currentDomain := dm.status.Tunnel.Domain
+			dm.mu.Unlock()
+
+			if currentDomain != domain {
+				dm.setTunnelInfo(TunnelTypeQuick, domain)
+
+				// 触发订阅更新
+				go func() {
+					time.Sleep(2 * time.Second)
+					generateSubscription(domain)
+				}()
 			}
 		}
 	}
 }
 
+// handleQUICFallback reacts to a "udp session registration" failure seen
+// while running on quic: if it happens inside quicFallbackWindow of the
+// current tunnel start, that's treated as quic being unreachable (blocked
+// UDP, NAT, ...) rather than a transient hiccup, so it forces one restart
+// on http2 instead. forcedTunnelProtocol then sticks for the rest of this
+// process's life (handleConfigReload clears it on the next TUNNEL_PROTOCOL
+// change), and quicFallbackTriggered keeps this from firing twice per run.
+func (dm *DaemonManager) handleQUICFallback() {
+	dm.mu.Lock()
+	eligible := dm.tunnelProtocol == TunnelProtocolQUIC &&
+		!dm.quicFallbackTriggered &&
+		time.Since(dm.tunnelStartedAt) <= quicFallbackWindow
+	if eligible {
+		dm.quicFallbackTriggered = true
+		dm.forcedTunnelProtocol = TunnelProtocolHTTP2
+	}
+	dm.mu.Unlock()
+
+	if !eligible {
+		return
+	}
+
+	eventLogger.Warn("quic udp session registration failed shortly after startup, falling back to http2",
+		"process", "tunnel", "event", "quic_fallback", "window", quicFallbackWindow)
+
+	tunnelType := dm.status.Tunnel.Type
+	go func() {
+		if err := prepareTunnelConfig(tunnelType); err != nil {
+			eventLogger.Error("failed to regenerate tunnel config for quic fallback",
+				"process", "tunnel", "event", "quic_fallback_error", "error", err)
+			return
+		}
+		dm.scheduleRestart("tunnel", "", nil)
+	}()
+}
+
+// recordEdgeFailure records a "failed to connect to edge" sighting and
+// trims entries older than edgeFailureWindow; once edgeFailureThreshold
+// failures land inside the window it calls p2pFallbackFunc (wired up in
+// main() to startP2PTunnel) exactly once per tunnel run, so a single burst
+// of edge failures trips the P2P fallback instead of one call per line.
+func (dm *DaemonManager) recordEdgeFailure() {
+	dm.mu.Lock()
+	now := time.Now()
+	dm.edgeFailures = append(dm.edgeFailures, now)
+	cutoff := now.Add(-edgeFailureWindow)
+	kept := dm.edgeFailures[:0]
+	for _, t := range dm.edgeFailures {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	dm.edgeFailures = kept
+
+	trip := len(dm.edgeFailures) >= edgeFailureThreshold && !dm.p2pFallbackTriggered
+	if trip {
+		dm.p2pFallbackTriggered = true
+	}
+	dm.mu.Unlock()
+
+	if trip {
+		eventLogger.Warn("repeated cloudflared edge failures, falling back to p2p tunnel",
+			"process", "tunnel", "event", "p2p_fallback", "failures", edgeFailureThreshold, "window", edgeFailureWindow)
+		if p2pFallbackFunc != nil {
+			go p2pFallbackFunc()
+		}
+	}
+}
+
 func (dm *DaemonManager) monitorProcess(name string, cmd *exec.Cmd) {
-	err := cmd.Wait()
+	waitErr := cmd.Wait()
 	dm.mu.Lock()
-	defer dm.mu.Unlock()
-	
-	log.Printf("%s process exited with code: %v", name, err)
+
+	eventLogger.Info("process exited", "process", name, "pid", cmd.Process.Pid, "event", "exited", "error", waitErr)
 	delete(dm.processes, name)
-	
+
 	if status, ok := dm.status.Processes[name]; ok {
 		status.Running = false
+		if metricsCollector != nil {
+			metricsCollector.SetProcessUp(name, false)
+			metricsCollector.ObserveProcessUptime(name, time.Since(status.LastStart).Seconds())
+		}
 	}
-	
+	dm.mu.Unlock()
+
 	// 如果不是正常退出，尝试重启
-	if err != nil {
-		log.Printf("%s exited abnormally, scheduling restart...", name)
+	if waitErr != nil {
+		eventLogger.Warn("process exited abnormally, scheduling restart", "process", name, "event", "restart_scheduled")
 		dm.scheduleRestart(name, cmd.Path, cmd.Args[1:])
 	}
-	
+
 	dm.saveStatus()
 }
 
+// scheduleRestart runs the per-process restart circuit breaker. While
+// Closed, failures are retried on the plain DaemonRestartDelay. Once
+// DaemonMaxRetries consecutive failures accumulate, the circuit trips Open
+// for a fully-jittered base*2^n backoff (capped at DaemonMaxBackoff) so
+// several processes dying together don't all hammer startProcess on the
+// same tick. After the backoff elapses the circuit goes HalfOpen and allows
+// exactly one probe restart: handleProcessOutput closes it again on a
+// success signal, while another failure here reopens it at the next
+// backoff bucket.
 func (dm *DaemonManager) scheduleRestart(name, command string, args []string) {
 	// 清除现有重启定时器
 	if timer, ok := dm.restartTimers[name]; ok {
 		timer.Stop()
 		delete(dm.restartTimers, name)
 	}
-	
+
 	dm.mu.Lock()
 	status := dm.status.Processes[name]
 	if status == nil {
 		status = &ProcessStatus{}
 		dm.status.Processes[name] = status
 	}
-	currentRetries := status.Retries
-	status.Retries++
-	dm.mu.Unlock()
-	
-	if currentRetries >= dm.config.DaemonMaxRetries {
-		log.Printf("%s has reached maximum restart attempts (%d)", name, dm.config.DaemonMaxRetries)
-		
-		// 等待一段时间后再重试
-		time.AfterFunc(60*time.Second, func() {
-			dm.mu.Lock()
-			if s, ok := dm.status.Processes[name]; ok {
-				s.Retries = 0
-			}
-			dm.mu.Unlock()
-			dm.scheduleRestart(name, command, args)
-		})
-		return
-	}
-	
-	// 计算延迟时间（指数退避）
-	delay := time.Duration(dm.config.DaemonRestartDelay) * time.Millisecond
-	for i := 0; i < currentRetries; i++ {
-		delay *= 2
+	if status.CircuitState == "" {
+		status.CircuitState = CircuitClosed
 	}
-	if delay > 60*time.Second {
-		delay = 60 * time.Second
+	status.Retries++
+
+	var delay time.Duration
+	if status.CircuitState == CircuitHalfOpen || status.Retries >= dm.cfg().DaemonMaxRetries {
+		status.CircuitState = CircuitOpen
+		status.OpenCount++
+		delay = dm.nextBackoff(status.OpenCount)
+		eventLogger.Error("circuit opened for process, backing off", "process", name, "event", "circuit_open",
+			"retries", status.Retries, "delay_ms", delay.Milliseconds())
+	} else {
+		delay = time.Duration(dm.cfg().DaemonRestartDelay) * time.Millisecond
+		eventLogger.Info("scheduling process restart", "process", name, "event", "restart_scheduled",
+			"retries", status.Retries, "delay_ms", delay.Milliseconds())
 	}
-	
-	log.Printf("Scheduling %s restart in %v (attempt %d/%d)", 
-		name, delay, currentRetries+1, dm.config.DaemonMaxRetries)
-	
+	status.NextAttemptAt = time.Now().Add(delay)
+	dm.mu.Unlock()
+	dm.saveStatus()
+
 	dm.restartTimers[name] = time.AfterFunc(delay, func() {
-		log.Printf("Restarting %s...", name)
+		dm.mu.Lock()
+		if status.CircuitState == CircuitOpen {
+			status.CircuitState = CircuitHalfOpen
+		}
+		dm.mu.Unlock()
+
+		eventLogger.Info("restarting process", "process", name, "event", "restart", "retries", status.Retries)
+		if metricsCollector != nil {
+			metricsCollector.IncProcessRestarts(name)
+		}
 		dm.startProcess(name, command, args)
 	})
 }
 
+// nextBackoff computes the Open-state backoff for the n-th trip of a
+// circuit: DaemonRestartDelay*2^(n-1) capped at DaemonMaxBackoff, then full
+// jitter per https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+// so simultaneous failures don't resynchronize into a restart storm.
+func (dm *DaemonManager) nextBackoff(openCount int) time.Duration {
+	maxDelay := time.Duration(dm.cfg().DaemonMaxBackoff) * time.Millisecond
+	delay := time.Duration(dm.cfg().DaemonRestartDelay) * time.Millisecond
+	for i := 1; i < openCount && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)))
+}
+
+// resetCircuit forces a process's circuit breaker back to Closed, cancels
+// any pending backoff timer, and clears its failure count so the next
+// restart attempt is immediate. Used by the POST /circuit/:name/reset
+// admin endpoint to recover a process an operator has already fixed by hand.
+func (dm *DaemonManager) resetCircuit(name string) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	status, ok := dm.status.Processes[name]
+	if !ok {
+		return fmt.Errorf("unknown process: %s", name)
+	}
+
+	if timer, ok := dm.restartTimers[name]; ok {
+		timer.Stop()
+		delete(dm.restartTimers, name)
+	}
+
+	status.CircuitState = CircuitClosed
+	status.Retries = 0
+	status.OpenCount = 0
+	status.NextAttemptAt = time.Time{}
+
+	eventLogger.Info("circuit reset to closed", "process", name, "event", "circuit_reset")
+	return nil
+}
+
 func (dm *DaemonManager) startHealthCheck(name string) {
 	// 清除现有检查定时器
 	if ticker, ok := dm.checkTickers[name]; ok {
 		ticker.Stop()
 		delete(dm.checkTickers, name)
 	}
-	
-	ticker := time.NewTicker(time.Duration(dm.config.DaemonCheckInterval) * time.Millisecond)
+
+	ticker := time.NewTicker(time.Duration(dm.cfg().DaemonCheckInterval) * time.Millisecond)
 	dm.checkTickers[name] = ticker
-	
+
 	go func() {
 		for range ticker.C {
 			dm.checkProcessHealth(name)
@@ -459,12 +1058,38 @@ func (dm *DaemonManager) startHealthCheck(name string) {
 }
 
 func (dm *DaemonManager) checkProcessHealth(name string) {
-	dm.mu.RLock()
-	cmd, ok := dm.processes[name]
-	dm.mu.RUnlock()
-	
-	if !ok || cmd == nil || cmd.Process == nil {
-		log.Printf("%s process not found, marking as dead", name)
+	if name == "nezha" {
+		healthy := nezhaAgent != nil && nezhaAgent.Healthy()
+		dm.mu.Lock()
+		if status, ok := dm.status.Processes[name]; ok {
+			status.Running = healthy
+		}
+		dm.mu.Unlock()
+		if metricsCollector != nil {
+			metricsCollector.SetProcessUp(name, healthy)
+		}
+		return
+	}
+
+	if name == "tunnel" && dm.status.Tunnel.Type == TunnelTypeP2P {
+		healthy := p2pAgent != nil && p2pAgent.Healthy()
+		dm.mu.Lock()
+		if status, ok := dm.status.Processes[name]; ok {
+			status.Running = healthy
+		}
+		dm.mu.Unlock()
+		if metricsCollector != nil {
+			metricsCollector.SetProcessUp(name, healthy)
+		}
+		return
+	}
+
+	dm.mu.RLock()
+	cmd, ok := dm.processes[name]
+	dm.mu.RUnlock()
+
+	if !ok || cmd == nil || cmd.Process == nil {
+		eventLogger.Warn("process not found, marking as dead", "process", name, "event", "health_check")
 		dm.mu.Lock()
 		if status, ok := dm.status.Processes[name]; ok {
 			status.Running = false
@@ -472,10 +1097,10 @@ func (dm *DaemonManager) checkProcessHealth(name string) {
 		dm.mu.Unlock()
 		return
 	}
-	
+
 	// 检查进程是否存在
 	if err := cmd.Process.Signal(syscall.Signal(0)); err != nil {
-		log.Printf("%s process (PID: %d) is dead", name, cmd.Process.Pid)
+		eventLogger.Warn("process is dead", "process", name, "pid", cmd.Process.Pid, "event", "health_check")
 		dm.mu.Lock()
 		if status, ok := dm.status.Processes[name]; ok {
 			status.Running = false
@@ -489,64 +1114,80 @@ func (dm *DaemonManager) checkProcessHealth(name string) {
 func (dm *DaemonManager) getAllStatus() map[string]interface{} {
 	dm.status.mu.RLock()
 	defer dm.status.mu.RUnlock()
-	
+
 	status := map[string]interface{}{
 		"nezha": map[string]interface{}{
-			"running":    dm.status.Processes["nezha"].Running,
-			"retries":    dm.status.Processes["nezha"].Retries,
-			"lastStart":  dm.status.Processes["nezha"].LastStart,
-			"name":       "哪吒监控代理",
+			"running":       dm.status.Processes["nezha"].Running,
+			"retries":       dm.status.Processes["nezha"].Retries,
+			"lastStart":     dm.status.Processes["nezha"].LastStart,
+			"name":          "哪吒监控代理",
+			"circuitState":  dm.status.Processes["nezha"].CircuitState,
+			"nextAttemptAt": dm.status.Processes["nezha"].NextAttemptAt,
 		},
 		"xray": map[string]interface{}{
-			"running":    dm.status.Processes["xray"].Running,
-			"retries":    dm.status.Processes["xray"].Retries,
-			"lastStart":  dm.status.Processes["xray"].LastStart,
-			"name":       "Xray代理服务",
+			"running":       dm.status.Processes["xray"].Running,
+			"retries":       dm.status.Processes["xray"].Retries,
+			"lastStart":     dm.status.Processes["xray"].LastStart,
+			"name":          "Xray代理服务",
+			"circuitState":  dm.status.Processes["xray"].CircuitState,
+			"nextAttemptAt": dm.status.Processes["xray"].NextAttemptAt,
 		},
 		"tunnel": map[string]interface{}{
-			"running":    dm.status.Processes["tunnel"].Running,
-			"retries":    dm.status.Processes["tunnel"].Retries,
-			"lastStart":  dm.status.Processes["tunnel"].LastStart,
-			"name":       dm.getTunnelDisplayName(),
-			"displayType": string(dm.status.Tunnel.Type),
-			"domain":     dm.status.Tunnel.Domain,
+			"running":       dm.status.Processes["tunnel"].Running,
+			"retries":       dm.status.Processes["tunnel"].Retries,
+			"lastStart":     dm.status.Processes["tunnel"].LastStart,
+			"name":          dm.getTunnelDisplayName(),
+			"displayType":   string(dm.status.Tunnel.Type),
+			"domain":        dm.status.Tunnel.Domain,
+			"colo":          dm.status.Tunnel.Colo,
+			"circuitState":  dm.status.Processes["tunnel"].CircuitState,
+			"nextAttemptAt": dm.status.Processes["tunnel"].NextAttemptAt,
 		},
 		"timestamp": time.Now(),
 		"uptime":    dm.status.Uptime,
 	}
-	
+
+	if cfIPOptimizer != nil {
+		status["cfip"] = map[string]interface{}{
+			"autoSelect": true,
+			"ranking":    cfIPOptimizer.Ranking(),
+		}
+	}
+
 	return status
 }
 
 func (dm *DaemonManager) getTunnelDisplayName() string {
 	switch dm.status.Tunnel.Type {
-	case TunnelFixed:
+	case TunnelTypeFixed:
 		return "Cloudflare固定隧道"
-	case TunnelToken:
+	case TunnelTypeToken:
 		return "Cloudflare Token隧道"
-	case TunnelTemporary:
-		return "Cloudflare临时隧道"
+	case TunnelTypeQuick:
+		return "Cloudflare快速隧道"
+	case TunnelTypeP2P:
+		return "P2P兜底隧道（QUIC直连）"
 	default:
 		return "Cloudflare隧道"
 	}
 }
 
 func (dm *DaemonManager) cleanup() {
-	log.Println("Cleaning up all daemon processes...")
-	
+	eventLogger.Info("cleaning up all daemon processes", "event", "cleanup")
+
 	dm.cancel()
-	
+
 	// 清理定时器
 	for name, ticker := range dm.checkTickers {
 		ticker.Stop()
 		delete(dm.checkTickers, name)
 	}
-	
+
 	for name, timer := range dm.restartTimers {
 		timer.Stop()
 		delete(dm.restartTimers, name)
 	}
-	
+
 	// 终止所有进程
 	for name, cmd := range dm.processes {
 		if cmd != nil && cmd.Process != nil {
@@ -554,7 +1195,7 @@ func (dm *DaemonManager) cleanup() {
 		}
 		delete(dm.processes, name)
 	}
-	
+
 	dm.saveStatus()
 }
 
@@ -564,7 +1205,7 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	
+
 	// 如果有index.html文件，则使用它
 	if _, err := os.Stat("index.html"); err == nil {
 		http.ServeFile(w, r, "index.html")
@@ -629,9 +1270,16 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
             <code>/restart/tunnel</code><br>
             <code>/restart/all</code>
         </div>
+        <div class="circuit">
+            <h2>Reset a Tripped Circuit</h2>
+            <p>Force a process's restart circuit back to closed:</p>
+            <code>/circuit/nezha/reset</code><br>
+            <code>/circuit/xray/reset</code><br>
+            <code>/circuit/tunnel/reset</code>
+        </div>
     </div>
 </body>
-</html>`, config.SubPath)
+</html>`, configStore.Load().SubPath)
 	}
 }
 
@@ -640,17 +1288,24 @@ func handleDaemonStatus(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+
+	cfg := configStore.Load()
+	resp := map[string]interface{}{
 		"success": true,
 		"data":    daemonManager.getAllStatus(),
 		"config": map[string]interface{}{
-			"checkInterval": config.DaemonCheckInterval,
-			"maxRetries":    config.DaemonMaxRetries,
-			"restartDelay":  config.DaemonRestartDelay,
+			"checkInterval": cfg.DaemonCheckInterval,
+			"maxRetries":    cfg.DaemonMaxRetries,
+			"restartDelay":  cfg.DaemonRestartDelay,
 		},
-	})
+	}
+	// ?logs=true服务最近的日志行，便于远程调试而无需直接访问日志文件
+	if r.URL.Query().Get("logs") == "true" {
+		resp["logs"] = recentLogLines()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 func handleRestart(w http.ResponseWriter, r *http.Request) {
@@ -658,27 +1313,27 @@ func handleRestart(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	
+
 	pathParts := strings.Split(r.URL.Path, "/")
 	if len(pathParts) < 3 {
 		http.Error(w, "Invalid path", http.StatusBadRequest)
 		return
 	}
-	
+
 	processName := pathParts[2]
-	
+
 	validProcesses := map[string]bool{
 		"nezha":  true,
 		"xray":   true,
 		"tunnel": true,
 		"all":    true,
 	}
-	
+
 	if !validProcesses[processName] {
 		http.Error(w, fmt.Sprintf("Invalid process name. Valid options: nezha, xray, tunnel, all"), http.StatusBadRequest)
 		return
 	}
-	
+
 	if processName == "all" {
 		// 重启所有进程
 		for name := range validProcesses {
@@ -688,7 +1343,7 @@ func handleRestart(w http.ResponseWriter, r *http.Request) {
 				}(name)
 			}
 		}
-		
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
@@ -696,7 +1351,7 @@ func handleRestart(w http.ResponseWriter, r *http.Request) {
 		})
 	} else {
 		go daemonManager.scheduleRestart(processName, "", nil)
-		
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
@@ -705,22 +1360,141 @@ func handleRestart(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleCircuitReset 处理 POST /circuit/:name/reset，强制将某进程的熔断器重置为Closed
+func handleCircuitReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) != 4 || pathParts[3] != "reset" {
+		http.Error(w, "Invalid path, expected /circuit/:name/reset", http.StatusBadRequest)
+		return
+	}
+
+	processName := pathParts[2]
+	validProcesses := map[string]bool{"nezha": true, "xray": true, "tunnel": true}
+	if !validProcesses[processName] {
+		http.Error(w, "Invalid process name. Valid options: nezha, xray, tunnel", http.StatusBadRequest)
+		return
+	}
+
+	if err := daemonManager.resetCircuit(processName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	daemonManager.saveStatus()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("%s circuit reset to closed", processName),
+	})
+}
+
+// handleTunnelSwitch 处理 POST /tunnel/switch，供运维人员手动强制切换隧道类型
+// （例如提前切到P2P兜底，或在误触发后切回cloudflared）。
+func handleTunnelSwitch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := configStore.Load()
+
+	var params struct {
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	switch TunnelType(params.Type) {
+	case TunnelTypeP2P:
+		if err := startP2PTunnel(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case TunnelTypeFixed, TunnelTypeToken, TunnelTypeQuick:
+		tunnelType := TunnelType(params.Type)
+		if err := prepareTunnelConfig(tunnelType); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		daemonManager.setTunnelInfo(tunnelType, cfg.ArgoDomain)
+		go daemonManager.scheduleRestart("tunnel", "", nil)
+	default:
+		http.Error(w, "invalid tunnel type, expected fixed|token|quick|p2p", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("tunnel switched to %s", params.Type),
+	})
+}
+
+// subscriptionUserAgents sniffs the client User-Agent for well-known
+// subscription apps when ?format= isn't given, so pasting a bare sub URL
+// into Clash/sing-box/Shadowrocket "just works" without picking a format.
+var subscriptionUserAgents = []struct {
+	substr string
+	format string
+}{
+	{"clash", "clash"},
+	{"stash", "clash"},
+	{"sing-box", "sing-box"},
+	{"sfi", "sing-box"},
+	{"shadowrocket", "shadowrocket"},
+}
+
+// selectSubscriptionFormat resolves the ?format= query param first, then
+// falls back to subscriptionUserAgents sniffing, then the original
+// v2ray-base64 default so existing subscription URLs keep working.
+func selectSubscriptionFormat(r *http.Request) SubscriptionFormatter {
+	if name := r.URL.Query().Get("format"); name != "" {
+		if f, ok := subscriptionFormatters[strings.ToLower(name)]; ok {
+			return f
+		}
+	}
+
+	ua := strings.ToLower(r.Header.Get("User-Agent"))
+	for _, m := range subscriptionUserAgents {
+		if strings.Contains(ua, m.substr) {
+			return subscriptionFormatters[m.format]
+		}
+	}
+
+	return subscriptionFormatters["v2ray-base64"]
+}
+
 func handleSubscription(w http.ResponseWriter, r *http.Request) {
-	subPath := filepath.Join(config.FilePath, "sub.txt")
+	cfg := configStore.Load()
+	formatter := selectSubscriptionFormat(r)
+
+	subPath := filepath.Join(cfg.FilePath, formatter.FileName())
 	if data, err := os.ReadFile(subPath); err == nil {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Type", formatter.ContentType())
 		w.Write(data)
-	} else {
-		// 如果没有订阅文件，生成一个简单的订阅
-		subTxt := fmt.Sprintf(`vless://%s@example.com:443?security=tls#Proxy-Server`, config.UUID)
-		encoded := base64.StdEncoding.EncodeToString([]byte(subTxt))
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.Write([]byte(encoded))
+		return
 	}
+
+	// 还没有隧道域名、订阅文件尚未生成时，返回一个占位节点
+	placeholder := ProxyNode{Name: "Proxy-Server", UUID: cfg.UUID, Server: "example.com", Port: 443, Host: "example.com"}
+	data, err := formatter.Format([]ProxyNode{placeholder})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", formatter.ContentType())
+	w.Write(data)
 }
 
 // 生成Xray配置文件
 func generateConfig() error {
+	cfg := configStore.Load()
 	configData := map[string]interface{}{
 		"log": map[string]interface{}{
 			"access":   "/dev/null",
@@ -743,7 +1517,7 @@ func generateConfig() error {
 				"protocol": "vless",
 				"settings": map[string]interface{}{
 					"clients": []map[string]interface{}{{
-						"id":   config.UUID,
+						"id":   cfg.UUID,
 						"flow": "xtls-rprx-vision",
 					}},
 					"decryption": "none",
@@ -764,13 +1538,13 @@ func generateConfig() error {
 				"protocol": "vless",
 				"settings": map[string]interface{}{
 					"clients": []map[string]interface{}{{
-						"id": config.UUID,
+						"id": cfg.UUID,
 					}},
 					"decryption": "none",
 				},
 				"streamSettings": map[string]interface{}{
-					"network":   "tcp",
-					"security":  "none",
+					"network":  "tcp",
+					"security": "none",
 				},
 			},
 			{
@@ -779,14 +1553,14 @@ func generateConfig() error {
 				"protocol": "vless",
 				"settings": map[string]interface{}{
 					"clients": []map[string]interface{}{{
-						"id":    config.UUID,
+						"id":    cfg.UUID,
 						"level": 0,
 					}},
 					"decryption": "none",
 				},
 				"streamSettings": map[string]interface{}{
-					"network":   "ws",
-					"security":  "none",
+					"network":  "ws",
+					"security": "none",
 					"wsSettings": map[string]interface{}{
 						"path": "/vless-argo",
 					},
@@ -803,7 +1577,7 @@ func generateConfig() error {
 				"protocol": "vmess",
 				"settings": map[string]interface{}{
 					"clients": []map[string]interface{}{{
-						"id":      config.UUID,
+						"id":      cfg.UUID,
 						"alterId": 0,
 					}},
 				},
@@ -825,12 +1599,12 @@ func generateConfig() error {
 				"protocol": "trojan",
 				"settings": map[string]interface{}{
 					"clients": []map[string]interface{}{{
-						"password": config.UUID,
+						"password": cfg.UUID,
 					}},
 				},
 				"streamSettings": map[string]interface{}{
-					"network":   "ws",
-					"security":  "none",
+					"network":  "ws",
+					"security": "none",
 					"wsSettings": map[string]interface{}{
 						"path": "/trojan-argo",
 					},
@@ -860,49 +1634,95 @@ func generateConfig() error {
 			"rules":          []interface{}{},
 		},
 	}
-	
+
 	data, err := json.MarshalIndent(configData, "", "  ")
 	if err != nil {
 		return err
 	}
-	
-	configPath := filepath.Join(config.FilePath, "config.json")
+
+	configPath := filepath.Join(cfg.FilePath, "config.json")
 	return os.WriteFile(configPath, data, 0644)
 }
 
-// 下载文件
+// 下载文件（未配置ASSET_MANIFEST_URL时的兜底路径，不做完整性校验）
 func downloadFile(fileName, fileUrl string) error {
 	resp, err := http.Get(fileUrl)
 	if err != nil {
 		return fmt.Errorf("failed to download %s: %v", fileName, err)
 	}
 	defer resp.Body.Close()
-	
+
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("failed to download %s: status %d", fileName, resp.StatusCode)
 	}
-	
-	filePath := filepath.Join(config.FilePath, fileName)
+
+	filePath := filepath.Join(configStore.Load().FilePath, fileName)
 	out, err := os.Create(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to create file %s: %v", fileName, err)
 	}
 	defer out.Close()
-	
+
 	_, err = io.Copy(out, resp.Body)
 	if err != nil {
 		return fmt.Errorf("failed to write file %s: %v", fileName, err)
 	}
-	
+
 	// 设置文件权限
 	if err := os.Chmod(filePath, 0755); err != nil {
-		log.Printf("Warning: Failed to set permissions for %s: %v", fileName, err)
+		eventLogger.Warn("failed to set file permissions", "file", fileName, "event", "download", "error", err)
+	}
+
+	eventLogger.Info("downloaded file successfully", "file", fileName, "event", "download")
+	return nil
+}
+
+// fetchAsset将fileName落地到FilePath下：配置了assetManager时，走清单校验+缓存，
+// 再将校验过的文件硬链接（失败则复制）到目标路径；否则退回旧的直接下载逻辑。
+func fetchAsset(fileName, assetName, fileUrl string) error {
+	if assetManager == nil {
+		return downloadFile(fileName, fileUrl)
 	}
-	
-	log.Printf("Downloaded %s successfully", fileName)
+
+	cachedPath, err := assetManager.Ensure(assetName, getSystemArchitecture())
+	if err != nil {
+		return fmt.Errorf("failed to ensure asset %s: %v", assetName, err)
+	}
+
+	destPath := filepath.Join(configStore.Load().FilePath, fileName)
+	os.Remove(destPath)
+	if err := os.Link(cachedPath, destPath); err != nil {
+		if err := copyFile(cachedPath, destPath); err != nil {
+			return fmt.Errorf("failed to install asset %s: %v", assetName, err)
+		}
+	}
+	if err := os.Chmod(destPath, 0755); err != nil {
+		eventLogger.Warn("failed to set file permissions", "file", fileName, "event", "asset_install", "error", err)
+	}
+
+	eventLogger.Info("verified and installed asset", "file", fileName, "asset", assetName, "event", "asset_install")
 	return nil
 }
 
+// copyFile拷贝cachedPath的内容到destPath，用于cachedPath与destPath不在同一文件系统、
+// 无法硬链接的情况。
+func copyFile(srcPath, destPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	_, err = io.Copy(dest, src)
+	return err
+}
+
 // 获取系统架构
 func getSystemArchitecture() string {
 	arch := runtime.GOARCH
@@ -915,73 +1735,57 @@ func getSystemArchitecture() string {
 // 下载所需文件
 func downloadFiles() error {
 	architecture := getSystemArchitecture()
-	
+
 	var files []struct {
-		name string
-		url  string
+		name  string
+		asset string
+		url   string
 	}
-	
+
 	// 基础文件
 	if architecture == "arm" {
-		files = append(files, 
-			struct{ name, url string }{randomNames.webName, "https://arm64.ssss.nyc.mn/web"},
-			struct{ name, url string }{randomNames.botName, "https://arm64.ssss.nyc.mn/bot"},
+		files = append(files,
+			struct{ name, asset, url string }{randomNames.webName, "web", "https://arm64.ssss.nyc.mn/web"},
+			struct{ name, asset, url string }{randomNames.botName, "bot", "https://arm64.ssss.nyc.mn/bot"},
 		)
 	} else {
 		files = append(files,
-			struct{ name, url string }{randomNames.webName, "https://amd64.ssss.nyc.mn/web"},
-			struct{ name, url string }{randomNames.botName, "https://amd64.ssss.nyc.mn/bot"},
+			struct{ name, asset, url string }{randomNames.webName, "web", "https://amd64.ssss.nyc.mn/web"},
+			struct{ name, asset, url string }{randomNames.botName, "bot", "https://amd64.ssss.nyc.mn/bot"},
 		)
 	}
-	
-	// 哪吒代理文件
-	if config.NezhaServer != "" && config.NezhaKey != "" {
-		if config.NezhaPort != "" {
-			if architecture == "arm" {
-				files = append(files, struct{ name, url string }{randomNames.npmName, "https://arm64.ssss.nyc.mn/agent"})
-			} else {
-				files = append(files, struct{ name, url string }{randomNames.npmName, "https://amd64.ssss.nyc.mn/agent"})
-			}
-		} else {
-			if architecture == "arm" {
-				files = append(files, struct{ name, url string }{randomNames.phpName, "https://arm64.ssss.nyc.mn/v1"})
-			} else {
-				files = append(files, struct{ name, url string }{randomNames.phpName, "https://amd64.ssss.nyc.mn/v1"})
-			}
-		}
-	}
-	
+
 	// 并行下载文件
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(files))
-	
+
 	for _, file := range files {
 		wg.Add(1)
-		go func(name, url string) {
+		go func(name, asset, url string) {
 			defer wg.Done()
-			if err := downloadFile(name, url); err != nil {
+			if err := fetchAsset(name, asset, url); err != nil {
 				errChan <- err
 			}
-		}(file.name, file.url)
+		}(file.name, file.asset, file.url)
 	}
-	
+
 	wg.Wait()
 	close(errChan)
-	
+
 	// 检查错误
 	for err := range errChan {
 		if err != nil {
 			return err
 		}
 	}
-	
+
 	return nil
 }
 
 // 获取ISP信息
 func getMetaInfo() string {
 	client := &http.Client{Timeout: 3 * time.Second}
-	
+
 	// 尝试第一个API
 	resp, err := client.Get("https://ipapi.co/json/")
 	if err == nil {
@@ -995,7 +1799,7 @@ func getMetaInfo() string {
 			}
 		}
 	}
-	
+
 	// 尝试备用API
 	resp, err = client.Get("http://ip-api.com/json/")
 	if err == nil {
@@ -1011,135 +1815,205 @@ func getMetaInfo() string {
 			}
 		}
 	}
-	
+
 	return "Unknown"
 }
 
+// buildSubscriptionNodes returns the ProxyNodes generateSubscription renders.
+// When cfIPOptimizer is enabled and has ranked at least one healthy
+// candidate, it returns up to cfoptimizer's TopN nodes, one per candidate,
+// named "<nodeName>-<port>-<n>" (Best may return several candidates sharing
+// a port when only one port is reachable, so the index keeps clash/sing-box's
+// proxy-group entries distinct); otherwise it falls back to the single
+// cfg.CFIP/cfg.CFPort node, the pre-cfoptimizer behavior.
+func buildSubscriptionNodes(cfg *Config, nodeName, domain string) []ProxyNode {
+	if cfIPOptimizer != nil {
+		if best := cfIPOptimizer.Best(0); len(best) > 0 {
+			nodes := make([]ProxyNode, len(best))
+			for i, c := range best {
+				nodes[i] = ProxyNode{
+					Name:   fmt.Sprintf("%s-%d-%d", nodeName, c.Port, i+1),
+					UUID:   cfg.UUID,
+					Server: c.IP,
+					Port:   c.Port,
+					Host:   domain,
+				}
+			}
+			return nodes
+		}
+	}
+
+	return []ProxyNode{{
+		Name:   nodeName,
+		UUID:   cfg.UUID,
+		Server: cfg.CFIP,
+		Port:   cfg.CFPort,
+		Host:   domain,
+	}}
+}
+
 // 生成订阅
 func generateSubscription(domain string) {
+	cfg := configStore.Load()
 	if domain == "" {
-		log.Println("No tunnel domain available for subscription generation")
+		eventLogger.Warn("no tunnel domain available for subscription generation", "event", "subscription")
 		return
 	}
-	
+
 	isp := getMetaInfo()
 	nodeName := isp
-	if config.Name != "" {
-		nodeName = fmt.Sprintf("%s-%s", config.Name, isp)
-	}
-	
-	// 生成VMESS配置
-	vmess := map[string]interface{}{
-		"v":    "2",
-		"ps":   nodeName,
-		"add":  config.CFIP,
-		"port": config.CFPort,
-		"id":   config.UUID,
-		"aid":  "0",
-		"scy":  "none",
-		"net":  "ws",
-		"type": "none",
-		"host": domain,
-		"path": "/vmess-argo?ed=2560",
-		"tls":  "tls",
-		"sni":  domain,
-		"alpn": "",
-		"fp":   "firefox",
-	}
-	
-	vmessJSON, _ := json.Marshal(vmess)
-	vmessBase64 := base64.StdEncoding.EncodeToString(vmessJSON)
-	
-	subTxt := fmt.Sprintf(`vless://%s@%s:%d?encryption=none&security=tls&sni=%s&fp=firefox&type=ws&host=%s&path=%%2Fvless-argo%%3Fed%%3D2560#%s
-
-vmess://%s
-
-trojan://%s@%s:%d?security=tls&sni=%s&fp=firefox&type=ws&host=%s&path=%%2Ftrojan-argo%%3Fed%%3D2560#%s`,
-		config.UUID, config.CFIP, config.CFPort, domain, domain, nodeName,
-		vmessBase64,
-		config.UUID, config.CFIP, config.CFPort, domain, domain, nodeName,
-	)
-	
-	encoded := base64.StdEncoding.EncodeToString([]byte(subTxt))
-	
-	subPath := filepath.Join(config.FilePath, "sub.txt")
-	if err := os.WriteFile(subPath, []byte(encoded), 0644); err != nil {
-		log.Printf("Failed to save subscription: %v", err)
-	} else {
-		log.Printf("Subscription saved to %s", subPath)
+	if cfg.Name != "" {
+		nodeName = fmt.Sprintf("%s-%s", cfg.Name, isp)
+	}
+
+	nodes := buildSubscriptionNodes(cfg, nodeName, domain)
+
+	// 为每个已注册的SubscriptionFormatter渲染并落盘；v2ray-base64的输出额外
+	// 用于uploadSubscription，与改造前的上报行为保持一致
+	var v2rayEncoded string
+	for _, formatter := range subscriptionFormatters {
+		data, err := formatter.Format(nodes)
+		if err != nil {
+			eventLogger.Error("failed to render subscription", "format", formatter.Name(), "event", "subscription", "error", err)
+			continue
+		}
+
+		subPath := filepath.Join(cfg.FilePath, formatter.FileName())
+		if err := os.WriteFile(subPath, data, 0644); err != nil {
+			eventLogger.Error("failed to save subscription", "format", formatter.Name(), "event", "subscription", "error", err)
+			continue
+		}
+		eventLogger.Info("subscription saved", "format", formatter.Name(), "path", subPath, "event", "subscription")
+
+		if formatter.Name() == "v2ray-base64" {
+			v2rayEncoded = string(data)
+		}
 	}
-	
+
 	// 上传订阅
-	go uploadSubscription(encoded)
+	if v2rayEncoded != "" {
+		go uploadSubscription(v2rayEncoded)
+	}
 }
 
 // 上传订阅
 func uploadSubscription(subscription string) {
-	if config.UploadURL == "" {
+	cfg := configStore.Load()
+	if cfg.UploadURL == "" {
 		return
 	}
-	
-	if config.ProjectURL != "" {
-		subscriptionURL := fmt.Sprintf("%s/%s", config.ProjectURL, config.SubPath)
+
+	if cfg.ProjectURL != "" {
+		subscriptionURL := fmt.Sprintf("%s/%s", cfg.ProjectURL, cfg.SubPath)
 		data := map[string]interface{}{
 			"subscription": []string{subscriptionURL},
 		}
-		
+
 		jsonData, _ := json.Marshal(data)
-		_, err := http.Post(config.UploadURL+"/api/add-subscriptions", 
-			"application/json", 
+		_, err := http.Post(cfg.UploadURL+"/api/add-subscriptions",
+			"application/json",
 			bytes.NewBuffer(jsonData))
 		if err != nil {
-			log.Printf("Failed to upload subscription: %v", err)
+			eventLogger.Error("failed to upload subscription", "event", "subscription_upload", "error", err)
 		} else {
-			log.Println("Subscription uploaded successfully")
+			eventLogger.Info("subscription uploaded successfully", "event", "subscription_upload")
 		}
 	}
 }
 
-// 分析隧道类型
-func analyzeTunnelType() TunnelType {
-	log.Println("Analyzing tunnel configuration...")
-	
-	if config.ArgoAuth != "" {
-		if strings.Contains(config.ArgoAuth, "TunnelSecret") {
-			log.Println("Tunnel type: FIXED (JSON configuration)")
-			return TunnelFixed
-		} else if len(config.ArgoAuth) >= 120 && len(config.ArgoAuth) <= 250 {
-			log.Println("Tunnel type: TOKEN (Token authentication)")
-			return TunnelToken
+// Server 封装隧道生命周期相关的行为，便于脱离全局变量单独测试
+type Server struct {
+	config *Config
+}
+
+// NewServer 创建Server实例
+func NewServer(cfg *Config) *Server {
+	return &Server{config: cfg}
+}
+
+// isTunnelToken 判断ArgoAuth是否是cloudflared的token格式：
+// 要么是旧版AQEDAH...形状的字符串，要么是base64编码的JSON，内含AccountTag/TunnelSecret/TunnelID
+func isTunnelToken(argoAuth string) bool {
+	// 旧版token固定以AQEDAH开头（base64编码的protobuf），无需解码即可识别
+	if strings.HasPrefix(argoAuth, "AQEDAH") {
+		return true
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(argoAuth)
+	if err != nil {
+		decoded, err = base64.RawStdEncoding.DecodeString(argoAuth)
+		if err != nil {
+			return false
 		}
 	}
-	
-	log.Println("Tunnel type: TEMPORARY (Quick tunnel)")
-	return TunnelTemporary
+
+	var payload struct {
+		AccountTag   string `json:"AccountTag"`
+		TunnelSecret string `json:"TunnelSecret"`
+		TunnelID     string `json:"TunnelID"`
+	}
+	if err := json.Unmarshal(decoded, &payload); err != nil {
+		return false
+	}
+
+	return payload.AccountTag != "" && payload.TunnelSecret != "" && payload.TunnelID != ""
+}
+
+// analyzeTunnelType 分析隧道类型
+func (s *Server) analyzeTunnelType() TunnelType {
+	eventLogger.Info("analyzing tunnel configuration", "event", "tunnel_analyze")
+
+	if s.config.ArgoAuth != "" {
+		if strings.Contains(s.config.ArgoAuth, "TunnelSecret") {
+			eventLogger.Info("tunnel type resolved", "tunnel_type", "fixed", "event", "tunnel_analyze")
+			return TunnelTypeFixed
+		} else if isTunnelToken(s.config.ArgoAuth) {
+			eventLogger.Info("tunnel type resolved", "tunnel_type", "token", "event", "tunnel_analyze")
+			return TunnelTypeToken
+		}
+	}
+
+	if s.config.QuickTunnelCreds != nil {
+		eventLogger.Info("tunnel type resolved", "tunnel_type", "quick", "provisioned", true, "event", "tunnel_analyze")
+		return TunnelTypeQuick
+	}
+
+	eventLogger.Info("tunnel type resolved", "tunnel_type", "quick", "provisioned", false, "event", "tunnel_analyze")
+	return TunnelTypeQuick
+}
+
+// analyzeTunnelType 包级别的便捷封装，供历史调用方使用全局config
+func analyzeTunnelType() TunnelType {
+	return NewServer(configStore.Load()).analyzeTunnelType()
 }
 
 // 准备隧道配置
 func prepareTunnelConfig(tunnelType TunnelType) error {
+	cfg := configStore.Load()
+	protocol := daemonManager.effectiveTunnelProtocol(cfg)
 	switch tunnelType {
-	case TunnelFixed:
+	case TunnelTypeFixed:
 		// 生成固定隧道配置文件
 		var tunnelConfig map[string]interface{}
-		if err := json.Unmarshal([]byte(config.ArgoAuth), &tunnelConfig); err != nil {
+		if err := json.Unmarshal([]byte(cfg.ArgoAuth), &tunnelConfig); err != nil {
 			return err
 		}
-		
+
 		tunnelID, ok := tunnelConfig["TunnelID"].(string)
 		if !ok {
 			return fmt.Errorf("invalid tunnel configuration")
 		}
-		
+
 		// 保存tunnel.json
-		tunnelJSONPath := filepath.Join(config.FilePath, "tunnel.json")
-		if err := os.WriteFile(tunnelJSONPath, []byte(config.ArgoAuth), 0644); err != nil {
+		tunnelJSONPath := filepath.Join(cfg.FilePath, "tunnel.json")
+		if err := os.WriteFile(tunnelJSONPath, []byte(cfg.ArgoAuth), 0644); err != nil {
 			return err
 		}
-		
+
 		// 生成tunnel.yml
 		tunnelYAML := fmt.Sprintf(`tunnel: %s
 credentials-file: %s
-protocol: http2
+protocol: %s
 
 ingress:
   - hostname: %s
@@ -1147,363 +2021,593 @@ ingress:
     originRequest:
       noTLSVerify: true
   - service: http_status:404
-`, tunnelID, tunnelJSONPath, config.ArgoDomain, config.ExternalPort)
-		
-		tunnelYAMLPath := filepath.Join(config.FilePath, "tunnel.yml")
+`, tunnelID, tunnelJSONPath, protocol, cfg.ArgoDomain, cfg.ExternalPort)
+
+		tunnelYAMLPath := filepath.Join(cfg.FilePath, "tunnel.yml")
 		if err := os.WriteFile(tunnelYAMLPath, []byte(tunnelYAML), 0644); err != nil {
 			return err
 		}
-		
-		log.Println("Fixed tunnel configuration generated successfully")
-		
-	case TunnelToken:
-		log.Println("Token tunnel requires no additional configuration")
-		
-	case TunnelTemporary:
-		log.Println("Temporary tunnel requires no additional configuration")
+
+		eventLogger.Info("fixed tunnel configuration generated", "event", "tunnel_config")
+
+	case TunnelTypeToken:
+		eventLogger.Info("token tunnel requires no additional configuration", "event", "tunnel_config")
+
+	case TunnelTypeQuick:
+		if cfg.QuickTunnelCreds == nil {
+			creds, err := provisionQuickTunnel(generateRandomName(8))
+			if err != nil {
+				return fmt.Errorf("failed to provision quick tunnel: %v", err)
+			}
+			updated := *cfg
+			updated.QuickTunnelCreds = creds
+			configStore.Store(&updated)
+			cfg = &updated
+		}
+
+		credsJSON, err := json.Marshal(cfg.QuickTunnelCreds)
+		if err != nil {
+			return err
+		}
+
+		tunnelJSONPath := filepath.Join(cfg.FilePath, "tunnel.json")
+		if err := os.WriteFile(tunnelJSONPath, credsJSON, 0644); err != nil {
+			return err
+		}
+
+		tunnelYAML := fmt.Sprintf(`tunnel: %s
+credentials-file: %s
+protocol: %s
+
+ingress:
+  - hostname: %s
+    service: http://localhost:%d
+    originRequest:
+      noTLSVerify: true
+  - service: http_status:404
+`, cfg.QuickTunnelCreds.TunnelID, tunnelJSONPath, protocol, cfg.QuickTunnelCreds.Hostname, cfg.ExternalPort)
+
+		tunnelYAMLPath := filepath.Join(cfg.FilePath, "tunnel.yml")
+		if err := os.WriteFile(tunnelYAMLPath, []byte(tunnelYAML), 0644); err != nil {
+			return err
+		}
+
+		eventLogger.Info("quick tunnel provisioned", "tunnel_name", cfg.QuickTunnelCreds.TunnelName, "event", "tunnel_config")
 	}
-	
+
 	return nil
 }
 
-// 启动哪吒代理
+// cloudflareAPIBase是Cloudflare官方REST API的根地址，provisionQuickTunnel/
+// deprovisionQuickTunnel用它创建/删除真实的Cloudflare Tunnel（cfd_tunnel）资源，
+// 取代之前指向一个并不存在的api.trycloudflare.com端点的实现
+const cloudflareAPIBase = "https://api.cloudflare.com/client/v4"
+
+// cfTunnelSecretBytes是客户端生成的隧道密钥长度，与cloudflared自己创建具名隧道时
+// 生成的tunnel secret长度一致
+const cfTunnelSecretBytes = 32
+
+// cfAPIResponse是Cloudflare API统一的响应信封；result的具体结构按端点各自解析
+type cfAPIResponse struct {
+	Success bool            `json:"success"`
+	Errors  []cfAPIError    `json:"errors"`
+	Result  json.RawMessage `json:"result"`
+}
+
+type cfAPIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// cfAPIRequest向Cloudflare API发起一次带Bearer鉴权的请求，并在success=false时
+// 把errors数组折叠进一个error里返回
+func cfAPIRequest(cfg *Config, method, path string, body interface{}) (*cfAPIResponse, error) {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, cloudflareAPIBase+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.CFAPIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out cfAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode Cloudflare API response: %v", err)
+	}
+	if !out.Success {
+		return nil, fmt.Errorf("Cloudflare API request failed (http %d): %+v", resp.StatusCode, out.Errors)
+	}
+	return &out, nil
+}
+
+// provisionQuickTunnel通过Cloudflare官方Tunnel API（POST .../cfd_tunnel）创建一个
+// 具名隧道，客户端自行生成tunnel secret，与cloudflared CLI创建具名隧道时的做法一致。
+// CF_API_TOKEN/CF_ACCOUNT_ID留空则直接报错；CF_ZONE_ID/QUICK_TUNNEL_DOMAIN都设置时
+// 额外在该zone下创建一条指向隧道的CNAME记录作为主机名
+func provisionQuickTunnel(name string) (*QuickTunnelCredentials, error) {
+	cfg := configStore.Load()
+	if cfg.CFAPIToken == "" || cfg.CFAccountID == "" {
+		return nil, fmt.Errorf("CF_API_TOKEN/CF_ACCOUNT_ID not configured, cannot provision a named quick tunnel")
+	}
+
+	secret := make([]byte, cfTunnelSecretBytes)
+	if _, err := cryptorand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate tunnel secret: %v", err)
+	}
+	secretB64 := base64.StdEncoding.EncodeToString(secret)
+
+	resp, err := cfAPIRequest(cfg, http.MethodPost, fmt.Sprintf("/accounts/%s/cfd_tunnel", cfg.CFAccountID), map[string]string{
+		"name":          name,
+		"tunnel_secret": secretB64,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tunnel: %v", err)
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(resp.Result, &created); err != nil {
+		return nil, fmt.Errorf("failed to parse tunnel create response: %v", err)
+	}
+
+	creds := &QuickTunnelCredentials{
+		AccountTag:   cfg.CFAccountID,
+		TunnelSecret: secretB64,
+		TunnelID:     created.ID,
+		TunnelName:   name,
+	}
+
+	if cfg.CFZoneID != "" && cfg.QuickTunnelDomain != "" {
+		hostname := fmt.Sprintf("%s.%s", name, cfg.QuickTunnelDomain)
+		if _, err := cfAPIRequest(cfg, http.MethodPost, fmt.Sprintf("/zones/%s/dns_records", cfg.CFZoneID), map[string]interface{}{
+			"type":    "CNAME",
+			"name":    hostname,
+			"content": created.ID + ".cfargotunnel.com",
+			"proxied": true,
+		}); err != nil {
+			deprovisionQuickTunnel(creds)
+			return nil, fmt.Errorf("failed to create DNS record for %s: %v", hostname, err)
+		}
+		creds.Hostname = hostname
+	}
+
+	return creds, nil
+}
+
+// deprovisionQuickTunnel在关闭或RotateCredentials时通过Cloudflare官方Tunnel API
+// （DELETE .../cfd_tunnel/:id）回收已签发的隧道，避免在账户下留下孤儿隧道；为它
+// 创建的DNS记录不会自动删除，留给账户自行清理
+func deprovisionQuickTunnel(creds *QuickTunnelCredentials) {
+	if creds == nil || creds.TunnelID == "" {
+		return
+	}
+
+	cfg := configStore.Load()
+	if cfg.CFAPIToken == "" || cfg.CFAccountID == "" {
+		return
+	}
+
+	if _, err := cfAPIRequest(cfg, http.MethodDelete, fmt.Sprintf("/accounts/%s/cfd_tunnel/%s", cfg.CFAccountID, creds.TunnelID), nil); err != nil {
+		eventLogger.Error("failed to deprovision quick tunnel", "tunnel_id", creds.TunnelID, "event", "tunnel_deprovision", "error", err)
+		return
+	}
+
+	eventLogger.Info("quick tunnel deprovisioned", "tunnel_id", creds.TunnelID, "event", "tunnel_deprovision")
+}
+
+// 启动哪吒代理（内嵌v1 gRPC agent，不再下载外部二进制）
 func startNezhaAgent() error {
-	if config.NezhaServer == "" || config.NezhaKey == "" {
-		log.Println("NEZHA variables are empty, skipping Nezha agent")
+	cfg := configStore.Load()
+	if cfg.NezhaServer == "" || cfg.NezhaKey == "" {
+		eventLogger.Info("nezha variables are empty, skipping nezha agent", "event", "nezha_start")
 		return nil
 	}
-	
-	var cmd *exec.Cmd
-	if config.NezhaPort == "" {
-		// 使用php版本
-		// 生成config.yaml
-		port := ""
-		if strings.Contains(config.NezhaServer, ":") {
-			parts := strings.Split(config.NezhaServer, ":")
-			if len(parts) > 1 {
-				port = parts[1]
-			}
-		}
-		
-		nezhatls := "false"
-		tlsPorts := []string{"443", "8443", "2096", "2087", "2083", "2053"}
-		for _, tlsPort := range tlsPorts {
-			if port == tlsPort {
-				nezhatls = "true"
-				break
-			}
-		}
-		
-		configYaml := fmt.Sprintf(`client_secret: %s
-debug: false
-disable_auto_update: true
-disable_command_execute: false
-disable_force_update: true
-disable_nat: false
-disable_send_query: false
-gpu: false
-insecure_tls: true
-ip_report_period: 1800
-report_delay: 4
-server: %s
-skip_connection_count: true
-skip_procs_count: true
-temperature: false
-tls: %s
-use_gitee_to_upgrade: false
-use_ipv6_country_code: false
-uuid: %s`, config.NezhaKey, config.NezhaServer, nezhatls, config.UUID)
-		
-		configPath := filepath.Join(config.FilePath, "config.yaml")
-		if err := os.WriteFile(configPath, []byte(configYaml), 0644); err != nil {
-			return err
-		}
-		
-		phpPath := filepath.Join(config.FilePath, randomNames.phpName)
-		cmd = exec.Command(phpPath, "-c", configPath)
-	} else {
-		// 使用agent版本
-		args := []string{
-			"-s", fmt.Sprintf("%s:%s", config.NezhaServer, config.NezhaPort),
-			"-p", config.NezhaKey,
-			"--disable-auto-update",
-			"--report-delay", "4",
-			"--skip-conn",
-			"--skip-procs",
-		}
-		
-		// 检查是否需要TLS
-		port, _ := strconv.Atoi(config.NezhaPort)
-		tlsPorts := map[int]bool{443: true, 8443: true, 2096: true, 2087: true, 2083: true, 2053: true}
-		if tlsPorts[port] {
-			args = append(args, "--tls")
-		}
-		
-		npmPath := filepath.Join(config.FilePath, randomNames.npmName)
-		cmd = exec.Command(npmPath, args...)
-	}
-	
-	return daemonManager.startProcess("nezha", cmd.Path, cmd.Args[1:])
+
+	server := cfg.NezhaServer
+	if cfg.NezhaPort != "" {
+		server = fmt.Sprintf("%s:%s", cfg.NezhaServer, cfg.NezhaPort)
+	}
+
+	nezhaAgent = nezha.New(nezha.Config{
+		Server:       server,
+		ClientSecret: cfg.NezhaKey,
+		ClientUUID:   cfg.UUID,
+	})
+
+	daemonManager.mu.Lock()
+	daemonManager.status.Processes["nezha"] = &ProcessStatus{LastStart: time.Now()}
+	daemonManager.mu.Unlock()
+
+	go nezhaAgent.Start(daemonManager.ctx)
+	daemonManager.startHealthCheck("nezha")
+
+	eventLogger.Info("embedded nezha v1 agent started", "server", server, "event", "nezha_start")
+	return nil
 }
 
 // 启动Xray
 func startXray() error {
-	webPath := filepath.Join(config.FilePath, randomNames.webName)
-	cmd := exec.Command(webPath, "-c", filepath.Join(config.FilePath, "config.json"))
-	
+	cfg := configStore.Load()
+	webPath := filepath.Join(cfg.FilePath, randomNames.webName)
+	cmd := exec.Command(webPath, "-c", filepath.Join(cfg.FilePath, "config.json"))
+
 	return daemonManager.startProcess("xray", cmd.Path, cmd.Args[1:])
 }
 
 // 启动Cloudflared隧道
 func startCloudflaredTunnel(tunnelType TunnelType) error {
-	botPath := filepath.Join(config.FilePath, randomNames.botName)
+	cfg := configStore.Load()
+	botPath := filepath.Join(cfg.FilePath, randomNames.botName)
 	if _, err := os.Stat(botPath); os.IsNotExist(err) {
 		return fmt.Errorf("cloudflared binary not found")
 	}
-	
+
+	protocol := daemonManager.effectiveTunnelProtocol(cfg)
+
 	var args []string
 	switch tunnelType {
-	case TunnelFixed:
+	case TunnelTypeFixed:
 		args = []string{
 			"tunnel",
 			"--edge-ip-version", "auto",
-			"--config", filepath.Join(config.FilePath, "tunnel.yml"),
+			"--config", filepath.Join(cfg.FilePath, "tunnel.yml"),
 			"run",
 		}
-		log.Println("Starting fixed tunnel with YAML configuration")
-		
-	case TunnelToken:
+		eventLogger.Info("starting fixed tunnel", "config", "yaml", "protocol", protocol, "event", "tunnel_start")
+
+	case TunnelTypeToken:
 		args = []string{
 			"tunnel",
 			"--edge-ip-version", "auto",
 			"--no-autoupdate",
-			"--protocol", "http2",
+			"--protocol", protocol,
 			"run",
-			"--token", config.ArgoAuth,
+			"--token", cfg.ArgoAuth,
 		}
-		
-		if config.ArgoDomain != "" {
-			args = append(args, "--hostname", config.ArgoDomain)
-			log.Printf("Token tunnel with hostname: %s", config.ArgoDomain)
+
+		if cfg.ArgoDomain != "" {
+			args = append(args, "--hostname", cfg.ArgoDomain)
+			eventLogger.Info("starting token tunnel", "hostname", cfg.ArgoDomain, "protocol", protocol, "event", "tunnel_start")
 		} else {
-			log.Println("Token tunnel without hostname (will use trycloudflare.com)")
+			eventLogger.Info("starting token tunnel without hostname, will use trycloudflare.com", "protocol", protocol, "event", "tunnel_start")
 			args = append(args,
-				"--logfile", filepath.Join(config.FilePath, "boot.log"),
+				"--logfile", filepath.Join(cfg.FilePath, "boot.log"),
 				"--loglevel", "info")
 		}
-		
-		log.Println("Starting token tunnel")
-		
-	case TunnelTemporary:
+
+		eventLogger.Info("starting token tunnel", "protocol", protocol, "event", "tunnel_start")
+
+	case TunnelTypeQuick:
 		args = []string{
 			"tunnel",
 			"--edge-ip-version", "auto",
-			"--no-autoupdate",
-			"--protocol", "http2",
-			"--logfile", filepath.Join(config.FilePath, "boot.log"),
-			"--loglevel", "info",
-			"--url", fmt.Sprintf("http://localhost:%d", config.ExternalPort),
+			"--config", filepath.Join(cfg.FilePath, "tunnel.yml"),
+			"run",
 		}
-		log.Println("Starting temporary tunnel")
+		eventLogger.Info("starting quick tunnel", "backend", "named-tunnel", "protocol", protocol, "event", "tunnel_start")
 	}
-	
+
+	daemonManager.mu.Lock()
+	daemonManager.tunnelProtocol = protocol
+	daemonManager.tunnelStartedAt = time.Now()
+	daemonManager.quicFallbackTriggered = false
+	daemonManager.mu.Unlock()
+
 	return daemonManager.startProcess("tunnel", botPath, args)
 }
 
 // 监控隧道域名
 func monitorTunnelDomain(tunnelType TunnelType) {
-	log.Println("Starting tunnel domain monitoring...")
-	
+	cfg := configStore.Load()
+	eventLogger.Info("starting tunnel domain monitoring", "event", "domain_monitor")
+
 	// 等待隧道启动
 	time.Sleep(10 * time.Second)
-	
+
 	switch tunnelType {
-	case TunnelFixed, TunnelToken:
-		if config.ArgoDomain != "" {
-			log.Printf("Using fixed/token tunnel domain: %s", config.ArgoDomain)
-			daemonManager.setTunnelInfo(tunnelType, config.ArgoDomain)
-			generateSubscription(config.ArgoDomain)
+	case TunnelTypeFixed, TunnelTypeToken:
+		if cfg.ArgoDomain != "" {
+			eventLogger.Info("using fixed/token tunnel domain", "domain", cfg.ArgoDomain, "event", "domain_monitor")
+			daemonManager.setTunnelInfo(tunnelType, cfg.ArgoDomain)
+			generateSubscription(cfg.ArgoDomain)
 		} else {
 			extractDomainFromLogs(tunnelType)
 		}
-	case TunnelTemporary:
-		extractDomainFromLogs(tunnelType)
+	case TunnelTypeQuick:
+		if cfg.QuickTunnelCreds != nil && cfg.QuickTunnelCreds.Hostname != "" {
+			eventLogger.Info("using quick tunnel hostname", "hostname", cfg.QuickTunnelCreds.Hostname, "event", "domain_monitor")
+			daemonManager.setTunnelInfo(tunnelType, cfg.QuickTunnelCreds.Hostname)
+			generateSubscription(cfg.QuickTunnelCreds.Hostname)
+		} else {
+			extractDomainFromLogs(tunnelType)
+		}
+	}
+}
+
+// scanBootLogForDomain reads bootLogPath and returns the first
+// trycloudflare.com hostname matched by tunnelDomainRegex, if any. A regex
+// over the whole file handles ANSI codes/timestamps around the URL and
+// multiple matching lines correctly, unlike a strings.Split on the suffix.
+func scanBootLogForDomain(bootLogPath string) (string, bool) {
+	data, err := os.ReadFile(bootLogPath)
+	if err != nil {
+		return "", false
+	}
+	m := tunnelDomainRegex.FindStringSubmatch(string(data))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// extractDomainFromLogsPolling is the bounded-backoff fallback used when an
+// fsnotify watcher can't be set up (no inotify support, boot.log's directory
+// missing, etc); it re-scans on the same doubling backoff schedule as the
+// watcher path until deadline fires.
+func extractDomainFromLogsPolling(tunnelType TunnelType, bootLogPath string, deadline <-chan time.Time) {
+	backoff := 500 * time.Millisecond
+	const maxBackoff = 5 * time.Second
+	for {
+		if domain, ok := scanBootLogForDomain(bootLogPath); ok {
+			eventLogger.Info("extracted tunnel domain from logs", "domain", domain, "event", "domain_monitor")
+			daemonManager.setTunnelInfo(tunnelType, domain)
+			generateSubscription(domain)
+			return
+		}
+		select {
+		case <-time.After(backoff):
+			if backoff < maxBackoff {
+				backoff *= 2
+			}
+		case <-deadline:
+			eventLogger.Error("failed to extract tunnel domain from logs", "event", "domain_monitor")
+			return
+		}
 	}
 }
 
 // 从日志中提取域名
+// extractDomainFromLogs watches boot.log via fsnotify so extraction fires on
+// write events instead of polling on a fixed schedule, falling back to
+// extractDomainFromLogsPolling if the watcher can't be set up. Either path
+// gives up after cfg.DomainExtractTimeoutMS.
 func extractDomainFromLogs(tunnelType TunnelType) {
-	bootLogPath := filepath.Join(config.FilePath, "boot.log")
-	for i := 0; i < 10; i++ {
-		if data, err := os.ReadFile(bootLogPath); err == nil {
-			content := string(data)
-			if strings.Contains(content, "trycloudflare.com") {
-				lines := strings.Split(content, "\n")
-				for _, line := range lines {
-					if strings.Contains(line, "trycloudflare.com") {
-						// 提取域名
-						parts := strings.Split(line, "trycloudflare.com")
-						if len(parts) > 0 {
-							replacer := strings.NewReplacer("https://", "", "http://", "", " ", "")
-							domain := replacer.Replace(parts[0]) + "trycloudflare.com"
-							log.Printf("Extracted tunnel domain: %s", domain)
-							daemonManager.setTunnelInfo(tunnelType, domain)
-							generateSubscription(domain)
-							return
-						}
-					}
-				}
+	cfg := configStore.Load()
+	bootLogPath := filepath.Join(cfg.FilePath, "boot.log")
+	timeout := time.Duration(cfg.DomainExtractTimeoutMS) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	deadline := time.After(timeout)
+
+	if domain, ok := scanBootLogForDomain(bootLogPath); ok {
+		eventLogger.Info("extracted tunnel domain from logs", "domain", domain, "event", "domain_monitor")
+		daemonManager.setTunnelInfo(tunnelType, domain)
+		generateSubscription(domain)
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		eventLogger.Warn("failed to create boot.log watcher, falling back to polling", "event", "domain_monitor", "error", err)
+		extractDomainFromLogsPolling(tunnelType, bootLogPath, deadline)
+		return
+	}
+	defer watcher.Close()
+
+	// 监听boot.log所在目录而非文件本身：cloudflared以O_TRUNC|O_CREATE重新打开
+	// 文件时，watch文件本身会丢失对新inode的监听
+	if err := watcher.Add(filepath.Dir(bootLogPath)); err != nil {
+		eventLogger.Warn("failed to watch boot.log directory, falling back to polling", "event", "domain_monitor", "error", err)
+		extractDomainFromLogsPolling(tunnelType, bootLogPath, deadline)
+		return
+	}
+
+	backoff := 500 * time.Millisecond
+	const maxBackoff = 5 * time.Second
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				extractDomainFromLogsPolling(tunnelType, bootLogPath, deadline)
+				return
+			}
+			if filepath.Base(event.Name) != "boot.log" || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if domain, ok := scanBootLogForDomain(bootLogPath); ok {
+				eventLogger.Info("extracted tunnel domain from logs", "domain", domain, "event", "domain_monitor")
+				daemonManager.setTunnelInfo(tunnelType, domain)
+				generateSubscription(domain)
+				return
+			}
+		case watchErr, ok := <-watcher.Errors:
+			if !ok {
+				extractDomainFromLogsPolling(tunnelType, bootLogPath, deadline)
+				return
+			}
+			eventLogger.Warn("boot.log watcher error", "event", "domain_monitor", "error", watchErr)
+		case <-time.After(backoff):
+			// 兜底：即使漏掉了某次write事件，也不会无限期卡住
+			if domain, ok := scanBootLogForDomain(bootLogPath); ok {
+				eventLogger.Info("extracted tunnel domain from logs", "domain", domain, "event", "domain_monitor")
+				daemonManager.setTunnelInfo(tunnelType, domain)
+				generateSubscription(domain)
+				return
+			}
+			if backoff < maxBackoff {
+				backoff *= 2
 			}
+		case <-deadline:
+			eventLogger.Error("failed to extract tunnel domain from logs", "event", "domain_monitor")
+			return
 		}
-		time.Sleep(5 * time.Second)
 	}
-	log.Println("Failed to extract tunnel domain")
 }
 
 // 添加访问任务
 func addVisitTask() {
-	if !config.AutoAccess || config.ProjectURL == "" {
-		log.Println("Skipping adding automatic access task")
+	cfg := configStore.Load()
+	if !cfg.AutoAccess || cfg.ProjectURL == "" {
+		eventLogger.Info("skipping automatic access task", "event", "visit_task")
 		return
 	}
-	
+
 	data := map[string]string{
-		"url": config.ProjectURL,
+		"url": cfg.ProjectURL,
 	}
-	
+
 	jsonData, _ := json.Marshal(data)
-	_, err := http.Post("https://oooo.serv00.net/add-url", 
-		"application/json", 
+	_, err := http.Post("https://oooo.serv00.net/add-url",
+		"application/json",
 		bytes.NewBuffer(jsonData))
-	
+
 	if err != nil {
-		log.Printf("Add automatic access task failed: %v", err)
+		eventLogger.Error("automatic access task failed", "event", "visit_task", "error", err)
 	} else {
-		log.Println("Automatic access task added successfully")
+		eventLogger.Info("automatic access task added successfully", "event", "visit_task")
 	}
 }
 
 // 清理旧文件
 func cleanupOldFiles() {
-	files, err := os.ReadDir(config.FilePath)
+	cfg := configStore.Load()
+	files, err := os.ReadDir(cfg.FilePath)
 	if err != nil {
 		return
 	}
-	
+
 	for _, file := range files {
 		if file.Name() != "daemon_status.json" && !file.IsDir() {
-			os.Remove(filepath.Join(config.FilePath, file.Name()))
+			os.Remove(filepath.Join(cfg.FilePath, file.Name()))
 		}
 	}
 }
 
 // 删除节点
 func deleteNodes() {
-	if config.UploadURL == "" {
+	cfg := configStore.Load()
+	if cfg.UploadURL == "" {
 		return
 	}
-	
-	subPath := filepath.Join(config.FilePath, "sub.txt")
+
+	subPath := filepath.Join(cfg.FilePath, "sub.txt")
 	if _, err := os.Stat(subPath); os.IsNotExist(err) {
 		return
 	}
-	
+
 	data, err := os.ReadFile(subPath)
 	if err != nil {
 		return
 	}
-	
+
 	decoded, err := base64.StdEncoding.DecodeString(string(data))
 	if err != nil {
 		return
 	}
-	
+
 	lines := strings.Split(string(decoded), "\n")
 	var nodes []string
 	for _, line := range lines {
-		if strings.Contains(line, "vless://") || 
-		   strings.Contains(line, "vmess://") || 
-		   strings.Contains(line, "trojan://") ||
-		   strings.Contains(line, "hysteria2://") || 
-		   strings.Contains(line, "tuic://") {
+		if strings.Contains(line, "vless://") ||
+			strings.Contains(line, "vmess://") ||
+			strings.Contains(line, "trojan://") ||
+			strings.Contains(line, "hysteria2://") ||
+			strings.Contains(line, "tuic://") {
 			nodes = append(nodes, line)
 		}
 	}
-	
+
 	if len(nodes) == 0 {
 		return
 	}
-	
+
 	jsonData, _ := json.Marshal(map[string]interface{}{"nodes": nodes})
-	http.Post(config.UploadURL+"/api/delete-nodes", 
-		"application/json", 
+	http.Post(cfg.UploadURL+"/api/delete-nodes",
+		"application/json",
 		bytes.NewBuffer(jsonData))
 }
 
 // 启动所有服务
 func startAllServices() error {
-	log.Println("Starting all services with daemon protection...")
-	
+	cfg := configStore.Load()
+	eventLogger.Info("starting all services with daemon protection", "event", "startup")
+
 	// 清理历史文件
 	deleteNodes()
 	cleanupOldFiles()
-	
+
 	// 生成Xray配置
 	if err := generateConfig(); err != nil {
 		return err
 	}
-	
+
 	// 下载文件
 	if err := downloadFiles(); err != nil {
 		return err
 	}
-	
+
 	// 分析隧道类型
 	tunnelType := analyzeTunnelType()
-	daemonManager.setTunnelInfo(tunnelType, config.ArgoDomain)
-	
+	daemonManager.setTunnelInfo(tunnelType, cfg.ArgoDomain)
+
 	// 准备隧道配置
 	if err := prepareTunnelConfig(tunnelType); err != nil {
 		return err
 	}
-	
+
 	// 启动服务
 	if err := startNezhaAgent(); err != nil {
 		return err
 	}
 	time.Sleep(2 * time.Second)
-	
+
 	if err := startXray(); err != nil {
 		return err
 	}
 	time.Sleep(2 * time.Second)
-	
+
 	if err := startCloudflaredTunnel(tunnelType); err != nil {
 		return err
 	}
-	
+
 	// 根据隧道类型设置等待时间
-	if tunnelType == TunnelFixed {
+	if tunnelType == TunnelTypeFixed {
 		time.Sleep(5 * time.Second)
 	} else {
 		time.Sleep(10 * time.Second)
 	}
-	
+
 	// 监控隧道域名
 	go monitorTunnelDomain(tunnelType)
-	
+
 	// 添加保活任务
 	go addVisitTask()
-	
-	log.Println("\n=== Server Initialization Complete ===")
-	log.Printf("HTTP Service:      http://localhost:%d", config.Port)
-	log.Printf("Proxy Service:     http://localhost:%d", config.ExternalPort)
-	log.Printf("Daemon Status:     http://localhost:%d/daemon-status", config.Port)
-	log.Printf("Subscription:      http://localhost:%d/%s", config.Port, config.SubPath)
-	log.Printf("Tunnel Type:       %s", tunnelType)
-	log.Printf("Tunnel Domain:     %s", config.ArgoDomain)
-	log.Println("=====================================\n")
-	
+
+	eventLogger.Info("server initialization complete",
+		"http_addr", fmt.Sprintf("http://localhost:%d", cfg.Port),
+		"proxy_addr", fmt.Sprintf("http://localhost:%d", cfg.ExternalPort),
+		"daemon_status_addr", fmt.Sprintf("http://localhost:%d/daemon-status", cfg.Port),
+		"subscription_addr", fmt.Sprintf("http://localhost:%d/%s", cfg.Port, cfg.SubPath),
+		"tunnel_type", tunnelType,
+		"tunnel_domain", cfg.ArgoDomain,
+		"event", "startup")
+
 	return nil
 }
 
@@ -1512,98 +2616,199 @@ type ProxyHandler struct{}
 
 func (p *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
-	
+
 	var target string
-	if strings.HasPrefix(path, "/vless-argo") || 
-	   strings.HasPrefix(path, "/vmess-argo") || 
-	   strings.HasPrefix(path, "/trojan-argo") ||
-	   path == "/vless" || 
-	   path == "/vmess" || 
-	   path == "/trojan" {
+	if strings.HasPrefix(path, "/vless-argo") ||
+		strings.HasPrefix(path, "/vmess-argo") ||
+		strings.HasPrefix(path, "/trojan-argo") ||
+		path == "/vless" ||
+		path == "/vmess" ||
+		path == "/trojan" {
 		target = "http://localhost:3001"
 	} else {
-		target = fmt.Sprintf("http://localhost:%d", config.Port)
+		target = fmt.Sprintf("http://localhost:%d", configStore.Load().Port)
 	}
-	
+
 	url, _ := url.Parse(target)
 	proxy := httputil.NewSingleHostReverseProxy(url)
-	proxy.ServeHTTP(w, r)
+
+	if metricsCollector == nil {
+		proxy.ServeHTTP(w, r)
+		return
+	}
+
+	activeConnections.Add(1)
+	defer activeConnections.Add(-1)
+	metricsCollector.SetActiveConnections(float64(activeConnections.Load()))
+
+	cw := &countingResponseWriter{ResponseWriter: w}
+	proxy.ServeHTTP(cw, r)
+	metricsCollector.AddBytesTransferred(float64(cw.bytes))
+}
+
+// activeConnections tracks in-flight proxied requests for the
+// tunnel_active_connections gauge.
+var activeConnections atomic.Int64
+
+// countingResponseWriter tallies bytes written through it, so ServeHTTP can
+// report tunnel_bytes_transferred once the proxied response completes.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	bytes int64
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
 }
 
 // 启动代理服务器
 func startProxyServer() {
+	cfg := configStore.Load()
 	proxy := &ProxyHandler{}
-	
+
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", config.ExternalPort),
+		Addr:    fmt.Sprintf(":%d", cfg.ExternalPort),
 		Handler: proxy,
 	}
-	
+
 	go func() {
-		log.Printf("Proxy server is running on port:%d!", config.ExternalPort)
-		log.Printf("HTTP traffic -> localhost:%d", config.Port)
-		log.Printf("Xray traffic -> localhost:3001")
-		
+		eventLogger.Info("proxy server starting", "proxy_port", cfg.ExternalPort, "http_addr", fmt.Sprintf("localhost:%d", cfg.Port), "xray_addr", "localhost:3001", "event", "startup")
+
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Proxy server failed: %v", err)
+			fatal("proxy server failed", "error", err)
 		}
 	}()
 }
 
 // 清理函数
+// stopGRPCServer/stopMetricsServer在main中被赋值为对应server的优雅关闭函数，cleanup时调用
+var stopGRPCServer = func() {}
+var stopMetricsServer = func() {}
+
 func cleanup() {
-	log.Println("\nReceived shutdown signal, cleaning up...")
+	eventLogger.Info("received shutdown signal, cleaning up", "event", "shutdown")
+	stopGRPCServer()
+	stopMetricsServer()
 	daemonManager.cleanup()
+	deprovisionQuickTunnel(configStore.Load().QuickTunnelCreds)
 	os.Exit(0)
 }
 
 func main() {
 	// 创建运行文件夹
-	config = NewConfig()
-	if _, err := os.Stat(config.FilePath); os.IsNotExist(err) {
-		os.MkdirAll(config.FilePath, 0755)
-		log.Printf("%s is created", config.FilePath)
+	configStore = NewConfigStore(NewConfig())
+	cfg := configStore.Load()
+
+	// 按LOG_LEVEL/LOG_FORMAT/LOG_FILE_PATH重建结构化日志器，再初始化守护进程管理器
+	eventLogger = newEventLogger(cfg)
+	daemonManager = NewDaemonManager(configStore)
+
+	if _, err := os.Stat(cfg.FilePath); os.IsNotExist(err) {
+		os.MkdirAll(cfg.FilePath, 0755)
+		eventLogger.Info("run directory created", "path", cfg.FilePath, "event", "startup")
 	} else {
-		log.Printf("%s already exists", config.FilePath)
+		eventLogger.Info("run directory already exists", "path", cfg.FilePath, "event", "startup")
+	}
+
+	// 初始化资产管理器（ASSET_MANIFEST_URL为空时downloadFiles退回旧的直接下载逻辑）
+	am, err := initAssetManager(cfg)
+	if err != nil {
+		fatal("failed to load asset manifest", "error", err)
 	}
-	
-	// 初始化守护进程管理器
-	daemonManager = NewDaemonManager(config)
-	
-	// 设置信号处理
+	assetManager = am
+
+	// 设置信号处理：SIGINT/SIGTERM退出，SIGHUP热重载配置
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
 	go func() {
 		<-sigChan
 		cleanup()
 	}()
-	
+	go func() {
+		for range hupChan {
+			eventLogger.Info("received SIGHUP, reloading config", "event", "config_reload")
+			if err := reloadConfig(); err != nil {
+				eventLogger.Error("config reload failed, keeping previous config", "event", "config_reload", "error", err)
+			}
+		}
+	}()
+
 	// 注册HTTP路由
 	http.HandleFunc("/", handleRoot)
 	http.HandleFunc("/daemon-status", handleDaemonStatus)
 	http.HandleFunc("/restart/", handleRestart)
-	http.HandleFunc("/"+config.SubPath, handleSubscription)
-	
+	http.HandleFunc("/circuit/", handleCircuitReset)
+	http.HandleFunc("/tunnel/switch", handleTunnelSwitch)
+	http.HandleFunc("/config/reload", handleConfigReload)
+	http.HandleFunc("/config/status", handleConfigStatus)
+	http.HandleFunc("/"+cfg.SubPath, handleSubscription)
+
+	// P2P兜底未配置P2P_RENDEZVOUS时recordEdgeFailure不会触发任何动作
+	p2pFallbackFunc = func() {
+		if err := startP2PTunnel(configStore.Load()); err != nil {
+			eventLogger.Error("failed to start p2p fallback tunnel", "event", "p2p_fallback", "error", err)
+		}
+	}
+	if assetManager != nil {
+		http.Handle("/assets/status", assetManager.StatusHandler())
+	}
+
+	// 注册WebSocket动作路由
+	wsRouter = newTunnelRouter(cfg, daemonManager)
+	http.HandleFunc("/ws", handleWebSocket)
+
+	// 启动Cloudflare优选IP探测（CFIP_AUTO_SELECT为false时跳过，沿用固定CFIP/CFPORT）；
+	// 必须在HTTP服务器开始监听之前完成赋值，否则/daemon-status和订阅生成可能读到
+	// 赋值前的nil
+	if cfg.CFIPAutoSelect {
+		cfIPOptimizer = cfoptimizer.New(cfoptimizer.Config{
+			RefreshInterval: time.Duration(cfg.CFIPRefreshIntervalMin) * time.Minute,
+		})
+		go cfIPOptimizer.Start(daemonManager.ctx)
+	}
+
 	// 启动HTTP服务器
 	go func() {
-		log.Printf("HTTP service is running on internal port:%d!", config.Port)
-		log.Printf("Daemon endpoints:")
-		log.Printf("  GET  /daemon-status  - Check all daemon processes status")
-		log.Printf("  POST /restart/:name  - Restart specific process (nezha/xray/tunnel/all)")
-		
-		if err := http.ListenAndServe(fmt.Sprintf(":%d", config.Port), nil); err != nil {
-			log.Fatalf("HTTP server failed: %v", err)
+		eventLogger.Info("HTTP service starting",
+			"internal_port", cfg.Port,
+			"endpoints", "GET /daemon-status, POST /restart/:name, POST /circuit/:name/reset, POST /config/reload",
+			"event", "startup")
+
+		if err := http.ListenAndServe(fmt.Sprintf(":%d", cfg.Port), nil); err != nil {
+			fatal("HTTP server failed", "error", err)
 		}
 	}()
-	
+
 	// 启动代理服务器
 	startProxyServer()
-	
-	// 启动所有服务
-	if err := startAllServices(); err != nil {
-		log.Fatalf("Failed to start services: %v", err)
+
+	// 启动gRPC控制面（GRPC_LISTEN为空时跳过）
+	stop, err := startGRPCServer(cfg, daemonManager)
+	if err != nil {
+		fatal("failed to start gRPC server", "error", err)
 	}
-	
+	stopGRPCServer = stop
+
+	// 启动Prometheus指标与状态面板（METRICS_ADDR为空时跳过）
+	metricsStop, err := startMetricsServer(cfg, daemonManager)
+	if err != nil {
+		fatal("failed to start metrics server", "error", err)
+	}
+	stopMetricsServer = metricsStop
+
+	// 启动所有服务（KUBERNETES_MODE下改为operator模式，由Tunnel CRD驱动）
+	if cfg.KubernetesMode {
+		if err := startKubernetesOperator(cfg); err != nil {
+			fatal("failed to start kubernetes operator", "error", err)
+		}
+	} else if err := startAllServices(); err != nil {
+		fatal("failed to start services", "error", err)
+	}
+
 	// 保持程序运行
 	select {}
 }
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestGenerateRandomName(t *testing.T) {
@@ -40,10 +47,10 @@ func TestAnalyzeTunnelType(t *testing.T) {
 		config: &Config{},
 	}
 
-	// 测试临时隧道
+	// 测试快速隧道（无ArgoAuth，走命名隧道后端）
 	s.config.ArgoAuth = ""
-	if result := s.analyzeTunnelType(); result != TunnelTypeTemporary {
-		t.Errorf("空配置期望临时隧道，实际: %s", result)
+	if result := s.analyzeTunnelType(); result != TunnelTypeQuick {
+		t.Errorf("空配置期望快速隧道，实际: %s", result)
 	}
 
 	// 测试固定隧道
@@ -52,9 +59,261 @@ func TestAnalyzeTunnelType(t *testing.T) {
 		t.Errorf("JSON配置期望固定隧道，实际: %s", result)
 	}
 
-	// 测试Token隧道
+	// 测试Token隧道（旧版字符串格式）
 	s.config.ArgoAuth = "AQEDAHh6eXq1tbW2t7i5vL3AwcHCw8TFxsfIycrLzM3Oz9DR0g=="
 	if result := s.analyzeTunnelType(); result != TunnelTypeToken {
 		t.Errorf("Token配置期望Token隧道，实际: %s", result)
 	}
+
+	// 测试Token隧道（新版base64编码的JSON格式）
+	jsonToken := `{"AccountTag":"36ac352c9bf67c5314dbfba731f2b319","TunnelSecret":"secret","TunnelID":"1aaffbbc-15bf-43e4-95e5-d7b0be86195b"}`
+	s.config.ArgoAuth = base64.StdEncoding.EncodeToString([]byte(jsonToken))
+	if result := s.analyzeTunnelType(); result != TunnelTypeToken {
+		t.Errorf("base64 JSON Token配置期望Token隧道，实际: %s", result)
+	}
+
+	// 测试已预先provisioning的快速隧道凭证
+	s.config.ArgoAuth = ""
+	s.config.QuickTunnelCreds = &QuickTunnelCredentials{TunnelID: "generated-id"}
+	if result := s.analyzeTunnelType(); result != TunnelTypeQuick {
+		t.Errorf("已有快速隧道凭证期望快速隧道，实际: %s", result)
+	}
+}
+
+func testProxyNode() ProxyNode {
+	return ProxyNode{
+		Name:   "test-node",
+		UUID:   "1aaffbbc-15bf-43e4-95e5-d7b0be86195b",
+		Server: "1.2.3.4",
+		Port:   443,
+		Host:   "tunnel.example.com",
+	}
+}
+
+func TestV2rayBase64FormatterRoundTrips(t *testing.T) {
+	data, err := v2rayBase64Formatter{}.Format([]ProxyNode{testProxyNode()})
+	if err != nil {
+		t.Fatalf("Format返回错误: %v", err)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		t.Fatalf("输出不是合法的base64: %v", err)
+	}
+
+	for _, want := range []string{"vless://", "vmess://", "trojan://"} {
+		if !strings.Contains(string(decoded), want) {
+			t.Errorf("解码后的订阅缺少%s链接: %s", want, decoded)
+		}
+	}
+}
+
+func TestShadowrocketFormatterIsPlainText(t *testing.T) {
+	data, err := shadowrocketFormatter{}.Format([]ProxyNode{testProxyNode()})
+	if err != nil {
+		t.Fatalf("Format返回错误: %v", err)
+	}
+
+	// shadowrocket格式不应整体base64编码，链接应直接可见
+	if !strings.Contains(string(data), "vless://") {
+		t.Errorf("期望明文vless链接，实际: %s", data)
+	}
+	if _, err := base64.StdEncoding.DecodeString(string(data)); err == nil {
+		t.Errorf("期望明文输出，但整体仍是合法base64: %s", data)
+	}
+}
+
+func TestClashFormatterYAMLSchema(t *testing.T) {
+	data, err := clashFormatter{}.Format([]ProxyNode{testProxyNode()})
+	if err != nil {
+		t.Fatalf("Format返回错误: %v", err)
+	}
+
+	var parsed clashConfig
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("输出不是合法的clash YAML: %v", err)
+	}
+
+	if len(parsed.Proxies) != 3 {
+		t.Errorf("期望3个代理节点，实际: %d", len(parsed.Proxies))
+	}
+	if len(parsed.ProxyGroups) != 1 || len(parsed.ProxyGroups[0].Proxies) != 3 {
+		t.Errorf("期望一个包含3个节点的代理组，实际: %+v", parsed.ProxyGroups)
+	}
+	if len(parsed.Rules) == 0 || !strings.Contains(parsed.Rules[0], "Proxy") {
+		t.Errorf("期望规则指向Proxy组，实际: %+v", parsed.Rules)
+	}
+}
+
+func TestSingBoxFormatterJSONSchema(t *testing.T) {
+	data, err := singBoxFormatter{}.Format([]ProxyNode{testProxyNode()})
+	if err != nil {
+		t.Fatalf("Format返回错误: %v", err)
+	}
+
+	var parsed struct {
+		Outbounds []map[string]interface{} `json:"outbounds"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("输出不是合法的JSON: %v", err)
+	}
+
+	if len(parsed.Outbounds) != 4 {
+		t.Errorf("期望4个outbound（vless/vmess/trojan/selector），实际: %d", len(parsed.Outbounds))
+	}
+
+	types := make(map[string]bool)
+	for _, ob := range parsed.Outbounds {
+		if obType, ok := ob["type"].(string); ok {
+			types[obType] = true
+		}
+	}
+	for _, want := range []string{"vless", "vmess", "trojan", "selector"} {
+		if !types[want] {
+			t.Errorf("outbounds中缺少type=%s", want)
+		}
+	}
+}
+
+func TestSelectSubscriptionFormatByQueryParam(t *testing.T) {
+	req := httptest.NewRequest("GET", "/sub?format=clash", nil)
+	if f := selectSubscriptionFormat(req); f.Name() != "clash" {
+		t.Errorf("期望?format=clash命中clash格式，实际: %s", f.Name())
+	}
+}
+
+// testDaemonManager构造一个独立的DaemonManager用于测试，并临时接管全局
+// configStore/daemonManager——prepareTunnelConfig/scheduleRestart等被
+// handleQUICFallback异步调用的代码路径读的是这两个全局变量，而不是
+// dm.store，和生产环境下单例daemon的假设一致。返回的cleanup会在测试结束时
+// 恢复原值并取消dm的context。
+func testDaemonManager(t *testing.T, cfg *Config) *DaemonManager {
+	t.Helper()
+
+	if cfg.FilePath == "" {
+		cfg.FilePath = t.TempDir()
+	}
+	if cfg.DaemonRestartDelay == 0 {
+		cfg.DaemonRestartDelay = 5
+	}
+
+	store := NewConfigStore(cfg)
+	dm := NewDaemonManager(store)
+
+	oldConfigStore, oldDaemonManager := configStore, daemonManager
+	configStore, daemonManager = store, dm
+	t.Cleanup(func() {
+		dm.cancel()
+		if timer, ok := dm.restartTimers["tunnel"]; ok {
+			timer.Stop()
+		}
+		configStore, daemonManager = oldConfigStore, oldDaemonManager
+	})
+
+	return dm
+}
+
+func TestHandleQUICFallbackEligibility(t *testing.T) {
+	tests := []struct {
+		name                  string
+		tunnelProtocol        string
+		quicFallbackTriggered bool
+		sinceStart            time.Duration
+		wantTriggered         bool
+	}{
+		{"quic协议窗口内失败应触发回退", TunnelProtocolQUIC, false, 1 * time.Second, true},
+		{"http2协议下不应触发回退", TunnelProtocolHTTP2, false, 1 * time.Second, false},
+		{"已经回退过一次不应重复触发", TunnelProtocolQUIC, true, 1 * time.Second, false},
+		{"超出quicFallbackWindow不应触发回退", TunnelProtocolQUIC, false, quicFallbackWindow + time.Second, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dm := testDaemonManager(t, &Config{})
+
+			dm.mu.Lock()
+			dm.tunnelProtocol = test.tunnelProtocol
+			dm.quicFallbackTriggered = test.quicFallbackTriggered
+			dm.tunnelStartedAt = time.Now().Add(-test.sinceStart)
+			dm.mu.Unlock()
+
+			dm.handleQUICFallback()
+
+			dm.mu.RLock()
+			triggered := dm.quicFallbackTriggered
+			forced := dm.forcedTunnelProtocol
+			dm.mu.RUnlock()
+
+			if triggered != test.wantTriggered {
+				t.Errorf("quicFallbackTriggered = %v，期望 %v", triggered, test.wantTriggered)
+			}
+			if test.wantTriggered && forced != TunnelProtocolHTTP2 {
+				t.Errorf("期望回退后forcedTunnelProtocol=http2，实际: %q", forced)
+			}
+			if !test.wantTriggered && test.quicFallbackTriggered && forced != "" {
+				t.Errorf("未触发新的回退时不应该改写forcedTunnelProtocol，实际: %q", forced)
+			}
+		})
+	}
+}
+
+// TestHandleProcessOutputQUICFallback模拟一个cloudflared子进程：把它在quic
+// 传输下typical的"udp session registration"失败日志喂给
+// handleProcessOutput解析stderr那条真实路径（而不是直接调用
+// handleTunnelOutput），验证协议协商/回退逻辑在日志解析这一端到端链路上
+// 也能正确触发。
+func TestHandleProcessOutputQUICFallback(t *testing.T) {
+	dm := testDaemonManager(t, &Config{})
+
+	dm.mu.Lock()
+	dm.tunnelProtocol = TunnelProtocolQUIC
+	dm.tunnelStartedAt = time.Now()
+	dm.mu.Unlock()
+
+	stdout := io.NopCloser(strings.NewReader("2026-07-29T00:00:00Z INF Starting tunnel tunnelID=test\n"))
+	stderr := io.NopCloser(strings.NewReader("2026-07-29T00:00:01Z ERR udp session registration error after 3 retries\n"))
+
+	dm.handleProcessOutput("tunnel", stdout, stderr)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		dm.mu.RLock()
+		triggered := dm.quicFallbackTriggered
+		forced := dm.forcedTunnelProtocol
+		dm.mu.RUnlock()
+		if triggered {
+			if forced != TunnelProtocolHTTP2 {
+				t.Errorf("期望回退协议为http2，实际: %q", forced)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("模拟的cloudflared udp session registration失败未能在预期时间内触发http2回退")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := dm.effectiveTunnelProtocol(&Config{TunnelProtocol: TunnelProtocolQUIC}); got != TunnelProtocolHTTP2 {
+		t.Errorf("期望回退生效后effectiveTunnelProtocol返回http2，实际: %s", got)
+	}
+}
+
+func TestSelectSubscriptionFormatByUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		expected  string
+	}{
+		{"ClashforWindows/0.20.0", "clash"},
+		{"sing-box/1.8.0", "sing-box"},
+		{"Shadowrocket/2.2.65", "shadowrocket"},
+		{"curl/8.0.1", "v2ray-base64"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/sub", nil)
+		req.Header.Set("User-Agent", test.userAgent)
+		if f := selectSubscriptionFormat(req); f.Name() != test.expected {
+			t.Errorf("User-Agent=%q期望格式%s，实际: %s", test.userAgent, test.expected, f.Name())
+		}
+	}
 }
@@ -0,0 +1,19 @@
+package metrics
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// DashboardHandler提供内嵌的状态页面。页面轮询"status"（相对于handler被
+// 挂载的路径）获取隧道列表，所有排序/过滤都在客户端完成，使服务端那一侧
+// 始终是个不带模板渲染的纯JSON端点。
+func DashboardHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboardHTML)
+	})
+}
@@ -0,0 +1,162 @@
+// Package metrics 把daemon的隧道状态暴露成Prometheus指标，并附带一个小型
+// 内嵌状态dashboard，使一批实例无需逐台ssh读日志即可监控。
+package metrics
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Collector持有某个daemon隧道的gauge/counter。TunnelType标签与
+// analyzeTunnelType返回的TunnelType*常量一致，使一次抓取反映的分类和daemon
+// 本身的判定完全一样。
+type Collector struct {
+	registry *prometheus.Registry
+
+	tunnelUp          *prometheus.GaugeVec
+	tunnelUptime      prometheus.Gauge
+	reconnectsTotal   prometheus.Counter
+	bytesTransferred  prometheus.Counter
+	activeConnections prometheus.Gauge
+
+	processUp            *prometheus.GaugeVec
+	processRestartsTotal *prometheus.CounterVec
+	processUptime        *prometheus.HistogramVec
+	tunnelInfo           *prometheus.GaugeVec
+
+	currentType  string
+	currentLabel string
+}
+
+// NewCollector构建一个注册在自己专属prometheus.Registry上的Collector，这样
+// 进程内同时存在多个Collector时（例如kubernetes backend下每个隧道一个）不会
+// 抢全局默认registry。
+func NewCollector() *Collector {
+	reg := prometheus.NewRegistry()
+
+	c := &Collector{
+		registry: reg,
+		tunnelUp: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tunnel_up",
+			Help: "Whether the tunnel is currently up (1) or down (0), labeled by tunnel type.",
+		}, []string{"type"}),
+		tunnelUptime: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "tunnel_uptime_seconds",
+			Help: "Seconds since the current tunnel process was last (re)started.",
+		}),
+		reconnectsTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "tunnel_reconnects_total",
+			Help: "Total number of times the tunnel process has been restarted.",
+		}),
+		bytesTransferred: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "tunnel_bytes_transferred",
+			Help: "Total bytes proxied through the tunnel.",
+		}),
+		activeConnections: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "tunnel_active_connections",
+			Help: "Number of currently active proxied connections.",
+		}),
+		processUp: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "argo_process_up",
+			Help: "Whether a daemon-managed process (nezha/xray/tunnel) is currently running.",
+		}, []string{"name"}),
+		processRestartsTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "argo_process_restarts_total",
+			Help: "Total number of times a daemon-managed process has been restarted.",
+		}, []string{"name"}),
+		processUptime: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "argo_process_uptime_seconds",
+			Help:    "How long a daemon-managed process ran before it exited or was restarted.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 10),
+		}, []string{"name"}),
+		tunnelInfo: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "argo_tunnel_info",
+			Help: "Always 1; labels carry the current tunnel type/domain for joins in Grafana.",
+		}, []string{"type", "domain"}),
+	}
+
+	return c
+}
+
+// SetTunnelUp记录隧道在给定type标签下的up/down状态，并把之前上报过的type
+// 清零，避免隧道被重新分类（例如配置重载后quick变成fixed）后留下一条卡在1
+// 的过期series。
+func (c *Collector) SetTunnelUp(tunnelType string, up bool) {
+	if c.currentType != "" && c.currentType != tunnelType {
+		c.tunnelUp.WithLabelValues(c.currentType).Set(0)
+	}
+	c.currentType = tunnelType
+
+	value := 0.0
+	if up {
+		value = 1.0
+	}
+	c.tunnelUp.WithLabelValues(tunnelType).Set(value)
+}
+
+// SetUptimeSeconds记录当前隧道进程已经运行了多久。
+func (c *Collector) SetUptimeSeconds(seconds float64) {
+	c.tunnelUptime.Set(seconds)
+}
+
+// IncReconnects记录一次隧道重启。
+func (c *Collector) IncReconnects() {
+	c.reconnectsTotal.Inc()
+}
+
+// AddBytesTransferred把n字节累加进转发字节数累计计数器。
+func (c *Collector) AddBytesTransferred(n float64) {
+	c.bytesTransferred.Add(n)
+}
+
+// SetActiveConnections记录当前被代理的连接数。
+func (c *Collector) SetActiveConnections(n float64) {
+	c.activeConnections.Set(n)
+}
+
+// SetProcessUp记录某个daemon管理的进程当前是否在运行。
+func (c *Collector) SetProcessUp(name string, up bool) {
+	value := 0.0
+	if up {
+		value = 1.0
+	}
+	c.processUp.WithLabelValues(name).Set(value)
+}
+
+// IncProcessRestarts记录一次daemon管理的进程重启。
+func (c *Collector) IncProcessRestarts(name string) {
+	c.processRestartsTotal.WithLabelValues(name).Inc()
+}
+
+// ObserveProcessUptime记录一个daemon管理的进程在退出或被重启之前运行了多久。
+func (c *Collector) ObserveProcessUptime(name string, seconds float64) {
+	c.processUptime.WithLabelValues(name).Observe(seconds)
+}
+
+// SetTunnelInfo把当前隧道的type/domain发布成一个恒为1的info指标，并把之前
+// 上报过的label组合清零，避免隧道被重新分类或重新配置后留下一条过期series。
+func (c *Collector) SetTunnelInfo(tunnelType, domain string) {
+	label := tunnelType + "|" + domain
+	if c.currentLabel != "" && c.currentLabel != label {
+		parts := strings.SplitN(c.currentLabel, "|", 2)
+		c.tunnelInfo.WithLabelValues(parts[0], parts[1]).Set(0)
+	}
+	c.currentLabel = label
+	c.tunnelInfo.WithLabelValues(tunnelType, domain).Set(1)
+}
+
+// Registry返回底层的prometheus.Registry，例如在调用Handler之前注册更多
+// collector。
+func (c *Collector) Registry() *prometheus.Registry {
+	return c.registry
+}
+
+// Handler以Prometheus exposition格式提供已采集的指标，客户端接受时会
+// gzip压缩。
+func (c *Collector) Handler() http.Handler {
+	return GzipMiddleware(promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGzipMiddlewareCompressesWhenAccepted(t *testing.T) {
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello metrics"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("期望Content-Encoding为gzip，实际: %q", rec.Header().Get("Content-Encoding"))
+	}
+
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("响应体不是合法的gzip: %v", err)
+	}
+	defer gz.Close()
+
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("解压响应体失败: %v", err)
+	}
+	if string(body) != "hello metrics" {
+		t.Fatalf("响应体不符合预期: %q", body)
+	}
+}
+
+func TestGzipMiddlewarePassesThroughWithoutAcceptEncoding(t *testing.T) {
+	handler := GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") == "gzip" {
+		t.Fatalf("没有Accept-Encoding header时不应该返回gzip编码")
+	}
+	if rec.Body.String() != "plain" {
+		t.Fatalf("响应体不符合预期: %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// TunnelStatusView是dashboard状态表格里的一行。调用方往Uptime里填的是
+// formatDuration生成的同一套可读字符串，使dashboard不必在JavaScript里
+// 重新实现一遍格式化。
+type TunnelStatusView struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Status  string `json:"status"` // "up"或"down"
+	Uptime  string `json:"uptime"`
+	Domain  string `json:"domain,omitempty"`
+	Retries int    `json:"retries"`
+}
+
+// StatusProvider返回当前要上报的隧道集合；单隧道daemon返回一条，kubernetes
+// operator则每个已协调的Tunnel CR各返回一条。
+type StatusProvider func() []TunnelStatusView
+
+// StatusHandler提供dashboard轮询的JSON，客户端接受时会gzip压缩。
+func StatusHandler(provider StatusProvider) http.Handler {
+	return GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(provider())
+	}))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"argo-go/metrics"
+)
+
+// metricsCollector在Config.MetricsAddr未设置时为nil；ProxyHandler会检查nil，
+// 使关闭metrics时热路径不多做额外工作。
+var metricsCollector *metrics.Collector
+
+// startMetricsServer在cfg.MetricsAddr上启动Prometheus端点和状态dashboard，
+// 每个抓取周期都从dm的status刷新一次gauge。MetricsAddr为空时是no-op（与
+// startGRPCServer一样）。
+func startMetricsServer(cfg *Config, dm *DaemonManager) (stop func(), err error) {
+	if cfg.MetricsAddr == "" {
+		return func() {}, nil
+	}
+
+	metricsCollector = metrics.NewCollector()
+
+	ticker := time.NewTicker(5 * time.Second)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				refreshMetrics(dm)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsCollector.Handler())
+	mux.Handle("/status", metrics.StatusHandler(func() []metrics.TunnelStatusView {
+		return tunnelStatusViews(dm)
+	}))
+	mux.Handle("/", metrics.DashboardHandler())
+
+	server := &http.Server{Addr: cfg.MetricsAddr, Handler: mux}
+
+	go func() {
+		eventLogger.Info("metrics endpoint and dashboard listening", "addr", cfg.MetricsAddr, "event", "startup")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			eventLogger.Error("metrics server stopped", "event", "metrics_stop", "error", err)
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		server.Close()
+	}, nil
+}
+
+// refreshMetrics把daemon当前的隧道和进程状态推送进Prometheus的
+// gauge/counter里。
+func refreshMetrics(dm *DaemonManager) {
+	dm.status.mu.RLock()
+	processes := make(map[string]bool, len(dm.status.Processes))
+	for name, status := range dm.status.Processes {
+		processes[name] = status.Running
+	}
+	tunnel := dm.status.Processes["tunnel"]
+	tunnelType := dm.status.Tunnel.Type
+	domain := dm.status.Tunnel.Domain
+	uptime := dm.status.Uptime
+	dm.status.mu.RUnlock()
+
+	for name, running := range processes {
+		metricsCollector.SetProcessUp(name, running)
+	}
+
+	if domain != "" {
+		metricsCollector.SetTunnelInfo(string(tunnelType), domain)
+	}
+
+	if tunnel == nil {
+		return
+	}
+
+	metricsCollector.SetTunnelUp(string(tunnelType), tunnel.Running)
+	metricsCollector.SetUptimeSeconds(uptime)
+}
+
+// tunnelStatusViews构建dashboard的行集合。daemon每个进程只跑一个隧道，所以
+// 这里总是单元素slice；kubernetes operator backend则是每个已协调的Tunnel CR
+// 各返回一行。
+func tunnelStatusViews(dm *DaemonManager) []metrics.TunnelStatusView {
+	dm.status.mu.RLock()
+	defer dm.status.mu.RUnlock()
+
+	tunnel := dm.status.Processes["tunnel"]
+	if tunnel == nil {
+		return nil
+	}
+
+	name := configStore.Load().Name
+	if name == "" {
+		name = "tunnel"
+	}
+
+	status := "down"
+	if tunnel.Running {
+		status = "up"
+	}
+
+	return []metrics.TunnelStatusView{{
+		Name:    name,
+		Type:    string(dm.status.Tunnel.Type),
+		Status:  status,
+		Uptime:  formatDuration(time.Duration(dm.status.Uptime) * time.Second),
+		Domain:  dm.status.Tunnel.Domain,
+		Retries: tunnel.Retries,
+	}}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"argo-go/internal/p2p"
+)
+
+// p2pAgent is nil until startP2PTunnel dials P2P_RENDEZVOUS; checkProcessHealth
+// special-cases the "tunnel" process when its type is TunnelTypeP2P to read
+// this agent's QUIC listener health instead of checking a cloudflared PID,
+// the same pattern nezhaAgent uses for the embedded Nezha agent.
+var p2pAgent *p2p.Agent
+
+// p2pFallbackFunc is assigned in main() to startP2PTunnel(config).
+// recordEdgeFailure calls it once its sliding window trips, so the daemon
+// package stays decoupled from the concrete p2p fallback implementation.
+var p2pFallbackFunc func()
+
+// startP2PTunnel brings up the WireGuard-style QUIC fallback described in
+// handleTunnelOutput/recordEdgeFailure: it registers this node with the
+// configured rendezvous server, publishes its UUID+pubkey, and forwards
+// inbound encrypted VLESS traffic to the local Xray inbound on
+// 127.0.0.1:3001. It also backs the operator-triggered POST /tunnel/switch
+// endpoint for forcing a switch to p2p ahead of time.
+func startP2PTunnel(cfg *Config) error {
+	if cfg.P2PRendezvous == "" {
+		return fmt.Errorf("P2P_RENDEZVOUS is not configured, cannot start p2p fallback tunnel")
+	}
+
+	agent := p2p.New(p2p.Config{
+		Rendezvous: cfg.P2PRendezvous,
+		NodeUUID:   cfg.UUID,
+		ForwardTo:  "127.0.0.1:3001",
+	})
+
+	peerID, err := agent.Register(daemonManager.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to register with rendezvous server: %v", err)
+	}
+	p2pAgent = agent
+
+	go agent.Serve(daemonManager.ctx)
+
+	daemonManager.mu.Lock()
+	daemonManager.status.Processes["tunnel"] = &ProcessStatus{Running: true, LastStart: time.Now()}
+	daemonManager.mu.Unlock()
+
+	domain := p2pDomain(cfg.P2PRendezvous, peerID)
+	daemonManager.setTunnelInfo(TunnelTypeP2P, domain)
+	go generateSubscription(domain)
+
+	eventLogger.Info("p2p fallback tunnel active", "peer_id", peerID, "rendezvous", cfg.P2PRendezvous, "event", "p2p_start")
+	return nil
+}
+
+// p2pDomain builds the peer-id.rendezvous.example hostname setTunnelInfo
+// records so generateSubscription can emit a working URI, stripping any
+// scheme/port from the configured rendezvous address.
+func p2pDomain(rendezvous, peerID string) string {
+	host := rendezvous
+	if u, err := url.Parse(rendezvous); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return fmt.Sprintf("%s.%s", peerID, host)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"argo-go/cfoptimizer"
+)
+
+// cfIPOptimizer is nil unless Config.CFIPAutoSelect is set; main starts it
+// with Config.CFIPRefreshIntervalMin, and buildSubscriptionNodes consults
+// its ranking instead of the single static cfg.CFIP/cfg.CFPort node.
+var cfIPOptimizer *cfoptimizer.Optimizer
+
+// ProxyNode is the single vless/vmess/trojan proxy this daemon exposes on
+// the argo tunnel, in the shape every SubscriptionFormatter renders from.
+type ProxyNode struct {
+	Name   string // 节点名称（展示用，通常是cfg.Name-ISP）
+	UUID   string
+	Server string // cfg.CFIP，客户端实际连接的优选IP/域名
+	Port   int    // cfg.CFPort
+	Host   string // argo隧道域名，同时用作ws Host头和TLS SNI
+}
+
+// SubscriptionFormatter renders one or more ProxyNodes into one subscription
+// client's native format. generateSubscription persists every registered
+// formatter's output under cfg.FilePath; handleSubscription picks one via
+// selectSubscriptionFormat. nodes has more than one entry when cfoptimizer
+// is enabled and ranked multiple CFIP candidates (see generateSubscription);
+// otherwise it's the single cfg.CFIP/cfg.CFPort node.
+type SubscriptionFormatter interface {
+	// Name identifies the format for ?format= matching and the
+	// subscriptionFormatters registry key.
+	Name() string
+	// FileName is the basename persisted under cfg.FilePath.
+	FileName() string
+	// ContentType is the MIME type served for this format.
+	ContentType() string
+	// Format renders nodes into the wire format clients expect.
+	Format(nodes []ProxyNode) ([]byte, error)
+}
+
+// subscriptionFormatters is the registry selectSubscriptionFormat and
+// generateSubscription both iterate/index by Name().
+var subscriptionFormatters = map[string]SubscriptionFormatter{
+	"v2ray-base64": v2rayBase64Formatter{},
+	"clash":        clashFormatter{},
+	"sing-box":     singBoxFormatter{},
+	"shadowrocket": shadowrocketFormatter{},
+}
+
+// buildVmessLink renders node as a "vmess://" link: a base64-encoded JSON
+// blob per the legacy v2rayN vmess share-link schema.
+func buildVmessLink(node ProxyNode) string {
+	vmess := map[string]interface{}{
+		"v":    "2",
+		"ps":   node.Name,
+		"add":  node.Server,
+		"port": node.Port,
+		"id":   node.UUID,
+		"aid":  "0",
+		"scy":  "none",
+		"net":  "ws",
+		"type": "none",
+		"host": node.Host,
+		"path": "/vmess-argo?ed=2560",
+		"tls":  "tls",
+		"sni":  node.Host,
+		"alpn": "",
+		"fp":   "firefox",
+	}
+	vmessJSON, _ := json.Marshal(vmess)
+	return "vmess://" + base64.StdEncoding.EncodeToString(vmessJSON)
+}
+
+// buildProxyURIs renders node's vless/vmess/trojan trio as plain
+// vless://, vmess://, trojan:// share links, one block per blank line.
+// v2rayBase64Formatter base64-encodes this whole blob; shadowrocketFormatter
+// serves it as-is, since Shadowrocket imports raw share-link lists directly.
+func buildProxyURIs(node ProxyNode) string {
+	return fmt.Sprintf(`vless://%s@%s:%d?encryption=none&security=tls&sni=%s&fp=firefox&type=ws&host=%s&path=%%2Fvless-argo%%3Fed%%3D2560#%s
+
+%s
+
+trojan://%s@%s:%d?security=tls&sni=%s&fp=firefox&type=ws&host=%s&path=%%2Ftrojan-argo%%3Fed%%3D2560#%s`,
+		node.UUID, node.Server, node.Port, node.Host, node.Host, node.Name,
+		buildVmessLink(node),
+		node.UUID, node.Server, node.Port, node.Host, node.Host, node.Name,
+	)
+}
+
+// v2rayBase64Formatter is the original sub.txt format: the vless/vmess/
+// trojan share links, base64-encoded as a single blob.
+type v2rayBase64Formatter struct{}
+
+func (v2rayBase64Formatter) Name() string        { return "v2ray-base64" }
+func (v2rayBase64Formatter) FileName() string    { return "sub.txt" }
+func (v2rayBase64Formatter) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (v2rayBase64Formatter) Format(nodes []ProxyNode) ([]byte, error) {
+	blocks := make([]string, len(nodes))
+	for i, node := range nodes {
+		blocks[i] = buildProxyURIs(node)
+	}
+	return []byte(base64.StdEncoding.EncodeToString([]byte(strings.Join(blocks, "\n\n")))), nil
+}
+
+// shadowrocketFormatter serves the same share links as v2rayBase64Formatter
+// but un-encoded, since Shadowrocket's subscription import accepts a raw
+// newline-separated link list directly.
+type shadowrocketFormatter struct{}
+
+func (shadowrocketFormatter) Name() string        { return "shadowrocket" }
+func (shadowrocketFormatter) FileName() string    { return "shadowrocket.txt" }
+func (shadowrocketFormatter) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (shadowrocketFormatter) Format(nodes []ProxyNode) ([]byte, error) {
+	blocks := make([]string, len(nodes))
+	for i, node := range nodes {
+		blocks[i] = buildProxyURIs(node)
+	}
+	return []byte(strings.Join(blocks, "\n\n")), nil
+}
+
+// clashWSOpts is the ws-opts block shared by every proxy type below.
+type clashWSOpts struct {
+	Path    string            `yaml:"path"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+type clashVlessProxy struct {
+	Name              string      `yaml:"name"`
+	Type              string      `yaml:"type"`
+	Server            string      `yaml:"server"`
+	Port              int         `yaml:"port"`
+	UUID              string      `yaml:"uuid"`
+	Network           string      `yaml:"network"`
+	TLS               bool        `yaml:"tls"`
+	UDP               bool        `yaml:"udp"`
+	Servername        string      `yaml:"servername"`
+	ClientFingerprint string      `yaml:"client-fingerprint"`
+	WSOpts            clashWSOpts `yaml:"ws-opts"`
+}
+
+type clashVmessProxy struct {
+	Name       string      `yaml:"name"`
+	Type       string      `yaml:"type"`
+	Server     string      `yaml:"server"`
+	Port       int         `yaml:"port"`
+	UUID       string      `yaml:"uuid"`
+	AlterID    int         `yaml:"alterId"`
+	Cipher     string      `yaml:"cipher"`
+	Network    string      `yaml:"network"`
+	TLS        bool        `yaml:"tls"`
+	UDP        bool        `yaml:"udp"`
+	Servername string      `yaml:"servername"`
+	WSOpts     clashWSOpts `yaml:"ws-opts"`
+}
+
+type clashTrojanProxy struct {
+	Name     string      `yaml:"name"`
+	Type     string      `yaml:"type"`
+	Server   string      `yaml:"server"`
+	Port     int         `yaml:"port"`
+	Password string      `yaml:"password"`
+	SNI      string      `yaml:"sni"`
+	Network  string      `yaml:"network"`
+	UDP      bool        `yaml:"udp"`
+	WSOpts   clashWSOpts `yaml:"ws-opts"`
+}
+
+type clashProxyGroup struct {
+	Name    string   `yaml:"name"`
+	Type    string   `yaml:"type"`
+	Proxies []string `yaml:"proxies"`
+}
+
+// clashConfig is the subset of a Clash Meta/Mihomo config that a
+// subscription needs: the proxy list, a single select group containing
+// them, and a catch-all rule pointing at that group.
+type clashConfig struct {
+	Proxies     []interface{}     `yaml:"proxies"`
+	ProxyGroups []clashProxyGroup `yaml:"proxy-groups"`
+	Rules       []string          `yaml:"rules"`
+}
+
+// clashFormatter renders node as a Clash Meta/Mihomo YAML config. Plain
+// Clash doesn't support vless, hence "Meta" - this targets the forks that
+// dominate real-world usage (Clash Verge, Clash Meta, Stash).
+type clashFormatter struct{}
+
+func (clashFormatter) Name() string        { return "clash" }
+func (clashFormatter) FileName() string    { return "clash.yaml" }
+func (clashFormatter) ContentType() string { return "text/yaml; charset=utf-8" }
+
+func (clashFormatter) Format(nodes []ProxyNode) ([]byte, error) {
+	var proxies []interface{}
+	var allNames []string
+
+	for _, node := range nodes {
+		names := []string{node.Name + "-vless", node.Name + "-vmess", node.Name + "-trojan"}
+		proxies = append(proxies,
+			clashVlessProxy{
+				Name: names[0], Type: "vless", Server: node.Server, Port: node.Port, UUID: node.UUID,
+				Network: "ws", TLS: true, UDP: true, Servername: node.Host, ClientFingerprint: "firefox",
+				WSOpts: clashWSOpts{Path: "/vless-argo?ed=2560", Headers: map[string]string{"Host": node.Host}},
+			},
+			clashVmessProxy{
+				Name: names[1], Type: "vmess", Server: node.Server, Port: node.Port, UUID: node.UUID,
+				AlterID: 0, Cipher: "none", Network: "ws", TLS: true, UDP: true, Servername: node.Host,
+				WSOpts: clashWSOpts{Path: "/vmess-argo?ed=2560", Headers: map[string]string{"Host": node.Host}},
+			},
+			clashTrojanProxy{
+				Name: names[2], Type: "trojan", Server: node.Server, Port: node.Port, Password: node.UUID,
+				SNI: node.Host, Network: "ws", UDP: true,
+				WSOpts: clashWSOpts{Path: "/trojan-argo?ed=2560", Headers: map[string]string{"Host": node.Host}},
+			},
+		)
+		allNames = append(allNames, names...)
+	}
+
+	cfg := clashConfig{
+		Proxies:     proxies,
+		ProxyGroups: []clashProxyGroup{{Name: "Proxy", Type: "select", Proxies: allNames}},
+		Rules:       []string{"MATCH,Proxy"},
+	}
+
+	return yaml.Marshal(cfg)
+}
+
+// singBoxFormatter renders node as a sing-box JSON config: an "outbounds"
+// array with one block per protocol plus a "selector" grouping them, the
+// same shape sing-box/SFI/NekoBox subscriptions expect.
+type singBoxFormatter struct{}
+
+func (singBoxFormatter) Name() string        { return "sing-box" }
+func (singBoxFormatter) FileName() string    { return "singbox.json" }
+func (singBoxFormatter) ContentType() string { return "application/json; charset=utf-8" }
+
+func (singBoxFormatter) Format(nodes []ProxyNode) ([]byte, error) {
+	var outbounds []map[string]interface{}
+	var allNames []string
+
+	for _, node := range nodes {
+		names := []string{node.Name + "-vless", node.Name + "-vmess", node.Name + "-trojan"}
+		tls := map[string]interface{}{"enabled": true, "server_name": node.Host}
+
+		outbounds = append(outbounds,
+			map[string]interface{}{
+				"type": "vless", "tag": names[0], "server": node.Server, "server_port": node.Port,
+				"uuid": node.UUID, "tls": tls,
+				"transport": map[string]interface{}{"type": "ws", "path": "/vless-argo?ed=2560", "headers": map[string]interface{}{"Host": node.Host}},
+			},
+			map[string]interface{}{
+				"type": "vmess", "tag": names[1], "server": node.Server, "server_port": node.Port,
+				"uuid": node.UUID, "security": "none", "alter_id": 0, "tls": tls,
+				"transport": map[string]interface{}{"type": "ws", "path": "/vmess-argo?ed=2560", "headers": map[string]interface{}{"Host": node.Host}},
+			},
+			map[string]interface{}{
+				"type": "trojan", "tag": names[2], "server": node.Server, "server_port": node.Port,
+				"password": node.UUID, "tls": tls,
+				"transport": map[string]interface{}{"type": "ws", "path": "/trojan-argo?ed=2560", "headers": map[string]interface{}{"Host": node.Host}},
+			},
+		)
+		allNames = append(allNames, names...)
+	}
+
+	outbounds = append(outbounds, map[string]interface{}{
+		"type": "selector", "tag": "Proxy", "outbounds": allNames, "default": allNames[0],
+	})
+
+	return json.MarshalIndent(map[string]interface{}{"outbounds": outbounds}, "", "  ")
+}
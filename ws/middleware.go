@@ -0,0 +1,66 @@
+package ws
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TokenAuth构造一个AuthFunc，要求给定的header（通常是"Authorization"或
+// "X-Auth-Token"）携带accepted token之一。
+func TokenAuth(header string, tokens ...string) AuthFunc {
+	accepted := make(map[string]bool, len(tokens))
+	for _, t := range tokens {
+		accepted[t] = true
+	}
+
+	return func(r *http.Request) error {
+		token := r.Header.Get(header)
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if !accepted[token] {
+			return fmt.Errorf("missing or invalid auth token")
+		}
+		return nil
+	}
+}
+
+// tokenBucket是一个极简的单连接限流器：每隔interval补充一个token，最多补到
+// burst上限。
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   int
+	burst    int
+	interval time.Duration
+	last     time.Time
+}
+
+// NewTokenBucketLimiter返回一个适合挂到Router.NewLimiter上的RateLimiter工厂：
+// 一开始允许burst条消息立即通过，之后每隔interval补充一个token。
+func NewTokenBucketLimiter(burst int, interval time.Duration) func() RateLimiter {
+	return func() RateLimiter {
+		return &tokenBucket{tokens: burst, burst: burst, interval: interval, last: time.Now()}
+	}
+}
+
+func (b *tokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	elapsed := time.Since(b.last)
+	if refill := int(elapsed / b.interval); refill > 0 {
+		b.tokens += refill
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = b.last.Add(time.Duration(refill) * b.interval)
+	}
+
+	if b.tokens <= 0 {
+		return false
+	}
+	b.tokens--
+	return true
+}
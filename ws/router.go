@@ -0,0 +1,146 @@
+// Package ws 实现一个基于action的轻量WebSocket路由器：每条入站消息都是一个
+// {"action": "...", "params": "..."}的JSON信封（params是JSON编码后的字符串），
+// 按action名分发给对应注册的handler，思路上类似常见的action-based websocket
+// 框架。
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Message是每一帧入站/出站消息使用的信封。
+type Message struct {
+	Action string `json:"action"`
+	Params string `json:"params,omitempty"`
+}
+
+// Context携带一条入站消息，以及在同一连接上回复或推送后续消息所需的手段。
+type Context struct {
+	Action    string
+	conn      *websocket.Conn
+	mu        *sync.Mutex
+	paramsRaw string
+}
+
+// Bind把Params（一个JSON编码的字符串）解码进v。
+func (c *Context) Bind(v interface{}) error {
+	return json.Unmarshal([]byte(c.paramsRaw), v)
+}
+
+// Reply把data序列化进Params后，以action为名回发给客户端。
+func (c *Context) Reply(action string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(Message{Action: action, Params: string(payload)})
+}
+
+// HandlerFunc处理一条被分发的消息。
+type HandlerFunc func(*Context) error
+
+// AuthFunc对一次连接升级请求做鉴权；返回error会在读取任何消息之前拒绝升级。
+type AuthFunc func(r *http.Request) error
+
+// RateLimiter限制单个连接每次能分发的消息数量；每条入站消息在交给handler
+// 之前都会检查一次。
+type RateLimiter interface {
+	Allow() bool
+}
+
+// Router把入站消息分发给已注册的handler。
+type Router struct {
+	upgrader   websocket.Upgrader
+	handlers   map[string]HandlerFunc
+	mu         sync.RWMutex
+	Auth       AuthFunc
+	NewLimiter func() RateLimiter
+}
+
+// NewRouter创建一个空的Router，调用ServeHTTP之前需要先用Register注册handler。
+func NewRouter() *Router {
+	return &Router{
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     func(r *http.Request) bool { return true },
+		},
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Register把fn注册为客户端发送{"action": action}时要运行的handler。
+func (router *Router) Register(action string, fn HandlerFunc) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	router.handlers[action] = fn
+}
+
+// ServeHTTP升级连接并持续分发消息，直到客户端断开或连接出错。
+func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if router.Auth != nil {
+		if err := router.Auth(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	conn, err := router.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("ws: upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	var limiter RateLimiter
+	if router.NewLimiter != nil {
+		limiter = router.NewLimiter()
+	}
+
+	writeMu := &sync.Mutex{}
+
+	for {
+		var msg Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		if limiter != nil && !limiter.Allow() {
+			writeMu.Lock()
+			conn.WriteJSON(Message{Action: "error", Params: `{"error":"rate limit exceeded"}`})
+			writeMu.Unlock()
+			continue
+		}
+
+		router.mu.RLock()
+		handler, ok := router.handlers[msg.Action]
+		router.mu.RUnlock()
+
+		if !ok {
+			writeMu.Lock()
+			conn.WriteJSON(Message{Action: "error", Params: fmt.Sprintf(`{"error":"unknown action %q"}`, msg.Action)})
+			writeMu.Unlock()
+			continue
+		}
+
+		ctx := &Context{Action: msg.Action, conn: conn, mu: writeMu, paramsRaw: msg.Params}
+		if ctx.paramsRaw == "" {
+			ctx.paramsRaw = "{}"
+		}
+
+		if err := handler(ctx); err != nil {
+			writeMu.Lock()
+			conn.WriteJSON(Message{Action: "error", Params: fmt.Sprintf(`{"error":%q}`, err.Error())})
+			writeMu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestRouterDispatch验证一次完整的往返，等价于用wscat连接后发送：
+// wscat -c ws://host/ws -x '{"action":"echo","params":"{\"msg\":\"hi\"}"}'
+func TestRouterDispatch(t *testing.T) {
+	router := NewRouter()
+	router.Register("echo", func(c *Context) error {
+		var params struct {
+			Msg string `json:"msg"`
+		}
+		if err := c.Bind(&params); err != nil {
+			return err
+		}
+		return c.Reply("echo.reply", map[string]string{"msg": params.Msg})
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(Message{Action: "echo", Params: `{"msg":"hi"}`}); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	var reply Message
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+
+	if reply.Action != "echo.reply" || !strings.Contains(reply.Params, "hi") {
+		t.Errorf("收到了意料之外的回复: %+v", reply)
+	}
+}
+
+func TestRouterUnknownAction(t *testing.T) {
+	router := NewRouter()
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(Message{Action: "does.not.exist"})
+
+	var reply Message
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if reply.Action != "error" {
+		t.Errorf("期望收到error回复，实际: %+v", reply)
+	}
+}
+
+func TestTokenAuthRejectsMissingToken(t *testing.T) {
+	auth := TokenAuth("X-Auth-Token", "secret")
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	if err := auth(req); err == nil {
+		t.Error("期望缺少token时返回错误")
+	}
+
+	req.Header.Set("X-Auth-Token", "secret")
+	if err := auth(req); err != nil {
+		t.Errorf("期望合法token通过校验，实际返回: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"argo-go/ws"
+)
+
+// newTunnelRouter把内置的tunnel.*系列action注册到一个ws.Router上，让客户端
+// （例如wscat -c ws://host/ws）能驱动与HTTP、gRPC控制面相同的操作。
+func newTunnelRouter(cfg *Config, dm *DaemonManager) *ws.Router {
+	router := ws.NewRouter()
+	router.NewLimiter = ws.NewTokenBucketLimiter(10, time.Second)
+	if cfg.WSAuthToken != "" {
+		router.Auth = ws.TokenAuth("X-Auth-Token", cfg.WSAuthToken)
+	}
+
+	router.Register("tunnel.status", func(c *ws.Context) error {
+		return c.Reply("tunnel.status", dm.getAllStatus())
+	})
+
+	router.Register("tunnel.restart", func(c *ws.Context) error {
+		var params struct {
+			Process string `json:"process"`
+		}
+		if err := c.Bind(&params); err != nil {
+			return err
+		}
+		if params.Process == "" {
+			params.Process = "tunnel"
+		}
+		go dm.scheduleRestart(params.Process, "", nil)
+		return c.Reply("tunnel.restart", map[string]string{"process": params.Process, "status": "restart scheduled"})
+	})
+
+	router.Register("tunnel.analyze", func(c *ws.Context) error {
+		var params struct {
+			ArgoAuth string `json:"argoAuth"`
+		}
+		if err := c.Bind(&params); err != nil {
+			return err
+		}
+		tunnelType := NewServer(&Config{ArgoAuth: params.ArgoAuth}).analyzeTunnelType()
+		return c.Reply("tunnel.analyze", map[string]string{"type": string(tunnelType)})
+	})
+
+	router.Register("tunnel.rename", func(c *ws.Context) error {
+		name := generateRandomName(6)
+		return c.Reply("tunnel.rename", map[string]string{"name": name})
+	})
+
+	router.Register("tunnel.uptime", func(c *ws.Context) error {
+		dm.status.mu.RLock()
+		uptime := time.Duration(dm.status.Uptime) * time.Second
+		dm.status.mu.RUnlock()
+
+		return c.Reply("tunnel.uptime", map[string]interface{}{
+			"seconds": uptime.Seconds(),
+			"human":   formatDuration(uptime),
+		})
+	})
+
+	return router
+}
+
+// handleWebSocket在main()里注册到/ws，转发给共享的tunnel action路由器。
+var wsRouter *ws.Router
+
+func handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	wsRouter.ServeHTTP(w, r)
+}